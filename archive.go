@@ -0,0 +1,394 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ArchiveFormatTarGz = "tar.gz"
+	ArchiveFormatZip   = "zip"
+
+	DefaultArchiveName = "{name}-{version}-{os}-{arch}"
+	ChecksumFile       = "SHA256SUMS"
+)
+
+// Packaging release archives bundle binary with extra files
+type Packaging struct {
+	Formats []string `yaml:"formats,omitempty"` // tar.gz or zip, default both
+	Name    string   `yaml:"name,omitempty"`    // archive base name template
+	Files   []string `yaml:"files,omitempty"`   // extra files or glob patterns like README.md LICENSE
+}
+
+type ArchiveFile struct {
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256"`
+}
+
+type Manifest struct {
+	*BuildInfo
+	Name     string        `json:"name"`
+	Package  string        `json:"package"`
+	OS       string        `json:"os"`
+	Arch     string        `json:"arch"`
+	Binary   ArchiveFile   `json:"binary"`
+	Files    []string      `json:"files"`
+	Archives []ArchiveFile `json:"archives"`
+}
+
+type archiveEntry struct {
+	source string
+	name   string
+}
+
+func (p *Packaging) formats() ([]string, error) {
+	if len(p.Formats) == 0 {
+		return []string{ArchiveFormatTarGz, ArchiveFormatZip}, nil
+	}
+	for _, f := range p.Formats {
+		if f != ArchiveFormatTarGz && f != ArchiveFormatZip {
+			return nil, errors.New("archive format `" + f + "` invalid, tar.gz or zip")
+		}
+	}
+	return p.Formats, nil
+}
+
+// entries binary on archive root, extra files keep relative path
+func (p *Packaging) entries(binaryPath, output string) ([]archiveEntry, error) {
+	entries := []archiveEntry{{source: binaryPath, name: output}}
+	seen := map[string]bool{output: true}
+
+	for _, pattern := range p.Files {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, errors.New("package file `" + pattern + "` not found")
+		}
+		sort.Strings(matches)
+
+		for _, m := range matches {
+			name := filepath.ToSlash(filepath.Clean(m))
+			if filepath.IsAbs(m) || strings.HasPrefix(name, "../") {
+				name = filepath.Base(m)
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			entries = append(entries, archiveEntry{source: m, name: name})
+		}
+	}
+
+	return entries, nil
+}
+
+func writeTarGz(archivePath, root string, entries []archiveEntry, modTime time.Time) error {
+	o, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+
+	gw := gzip.NewWriter(o)
+	tw := tar.NewWriter(gw)
+
+	for _, e := range entries {
+		if err := addTarEntry(tw, root, e, modTime); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return o.Close()
+}
+
+func addTarEntry(tw *tar.Writer, root string, e archiveEntry, modTime time.Time) error {
+	f, err := os.Open(e.source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() {
+		return errors.New("package file `" + e.source + "` not regular file")
+	}
+
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return err
+	}
+	header.Name = path.Join(root, e.name)
+	if !modTime.IsZero() {
+		header.ModTime = modTime
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+func writeZip(archivePath, root string, entries []archiveEntry, modTime time.Time) error {
+	o, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+
+	zw := zip.NewWriter(o)
+
+	for _, e := range entries {
+		if err := addZipEntry(zw, root, e, modTime); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return o.Close()
+}
+
+func addZipEntry(zw *zip.Writer, root string, e archiveEntry, modTime time.Time) error {
+	f, err := os.Open(e.source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() {
+		return errors.New("package file `" + e.source + "` not regular file")
+	}
+
+	header, err := zip.FileInfoHeader(stat)
+	if err != nil {
+		return err
+	}
+	header.Name = path.Join(root, e.name)
+	header.Method = zip.Deflate
+	if !modTime.IsZero() {
+		header.Modified = modTime
+	}
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func archiveBase(t Task, version string) string {
+	template := t.Package.Packaging.Name
+	if template == "" {
+		template = DefaultArchiveName
+	}
+	goOS, goArch := t.Package.Target()
+	return strings.NewReplacer("{version}", version, "{output}", t.Output).
+		Replace(OutputName(template, t.Name, goOS, goArch))
+}
+
+// archiveVersion version segment of archive name, `v` prefix kept from git tag
+const archiveVersion = `v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?`
+
+// archiveMatcher match archive and manifest file name of task in any version,
+// glob alone also match archives of package `api-admin` for `api`
+func archiveMatcher(t Task) (*regexp.Regexp, error) {
+	parts := strings.Split(archiveBase(t, "\x00"), "\x00")
+	for i := range parts {
+		parts[i] = regexp.QuoteMeta(parts[i])
+	}
+	return regexp.Compile("^" + strings.Join(parts, archiveVersion) + `\.(tar\.gz|zip|manifest\.json)$`)
+}
+
+// ArchiveFiles archives and manifest of task in any version
+func ArchiveFiles(t Task) ([]string, error) {
+	if t.Package.Packaging == nil {
+		return nil, nil
+	}
+	match, err := archiveMatcher(t)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := filepath.Glob(filepath.Join(t.Package.Dest, archiveBase(t, "*")+".*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, path := range candidates {
+		if match.MatchString(filepath.Base(path)) {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// PackageArtifact write archives and manifest of built binary next to it
+func PackageArtifact(t Task, binaryPath, binarySha256 string, info *BuildInfo) ([]ArchiveFile, error) {
+	p := t.Package.Packaging
+
+	formats, err := p.formats()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := p.entries(binaryPath, t.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	goOS, goArch := t.Package.Target()
+	base := archiveBase(t, info.Version)
+
+	manifest := Manifest{
+		BuildInfo: info,
+		Name:      t.Name,
+		Package:   t.Package.Package,
+		OS:        goOS,
+		Arch:      goArch,
+		Binary:    ArchiveFile{Path: t.Output, Sha256: binarySha256},
+	}
+	for _, e := range entries[1:] {
+		manifest.Files = append(manifest.Files, e.name)
+	}
+
+	// reproducible archive entries take build stamp as mod time
+	var modTime time.Time
+	if t.Package.Reproducible {
+		if modTime, err = time.Parse(time.RFC3339, info.BuildStamp); err != nil {
+			return nil, err
+		}
+	}
+
+	var archives []ArchiveFile
+	for _, format := range formats {
+		archivePath := filepath.Join(t.Package.Dest, base+"."+format)
+
+		write := writeTarGz
+		if format == ArchiveFormatZip {
+			write = writeZip
+		}
+		if err := write(archivePath, base, entries, modTime); err != nil {
+			return nil, err
+		}
+
+		signature, _, err := FileSignature(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		archives = append(archives, ArchiveFile{Path: archivePath, Sha256: hex.EncodeToString(signature)})
+		manifest.Archives = append(manifest.Archives, ArchiveFile{
+			Path:   filepath.Base(archivePath),
+			Sha256: hex.EncodeToString(signature),
+		})
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	manifestPath := filepath.Join(t.Package.Dest, base+".manifest.json")
+	if err := os.WriteFile(manifestPath, append(manifestBytes, '\n'), 0644); err != nil {
+		return nil, err
+	}
+
+	return archives, nil
+}
+
+func readChecksums(checksumPath string) (map[string]string, error) {
+	sums := make(map[string]string)
+
+	o, err := os.Open(checksumPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return sums, nil
+		}
+		return nil, err
+	}
+	defer o.Close()
+
+	scanner := bufio.NewScanner(o)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			sums[fields[1]] = fields[0]
+		}
+	}
+
+	return sums, scanner.Err()
+}
+
+// WriteChecksums merge archives of all results into SHA256SUMS of each dest directory
+func WriteChecksums(results []TaskResult) error {
+	byDir := make(map[string][]ArchiveFile)
+	for _, r := range results {
+		for _, a := range r.Archives {
+			dir := filepath.Dir(a.Path)
+			byDir[dir] = append(byDir[dir], a)
+		}
+	}
+
+	for dir, archives := range byDir {
+		checksumPath := filepath.Join(dir, ChecksumFile)
+
+		sums, err := readChecksums(checksumPath)
+		if err != nil {
+			return err
+		}
+		for _, a := range archives {
+			sums[filepath.Base(a.Path)] = a.Sha256
+		}
+
+		names := make([]string, 0, len(sums))
+		for name := range sums {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var b strings.Builder
+		for _, name := range names {
+			b.WriteString(sums[name] + "  " + name + "\n")
+		}
+
+		temp := checksumPath + ".tmp"
+		if err := os.WriteFile(temp, []byte(b.String()), 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(temp, checksumPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
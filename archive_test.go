@@ -0,0 +1,175 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestArchiveBase(t *testing.T) {
+	tests := []struct {
+		template, output, goOS, goArch, want string
+	}{
+		{"", "api", "linux", "amd64", "api-1.2.3-linux-amd64"},
+		{"", "api-windows-amd64.exe", "windows", "amd64", "api-1.2.3-windows-amd64"},
+		{"{name}_{version}_{os}_{arch}", "api", "darwin", "arm64", "api_1.2.3_darwin_arm64"},
+		{"{output}-{version}", "api-linux-arm64", "linux", "arm64", "api-linux-arm64-1.2.3"},
+	}
+
+	for _, test := range tests {
+		pkg := &GoBuilderPackage{BuildOS: test.goOS, BuildArch: test.goArch, Packaging: &Packaging{Name: test.template}}
+		if got := archiveBase(Task{Name: "api", Output: test.output, Package: pkg}, "1.2.3"); got != test.want {
+			t.Errorf("%q %s %s want %s", test.template, test.output, got, test.want)
+		}
+	}
+}
+
+func tarNames(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+}
+
+func zipNames(t *testing.T, path string) []string {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestPackageArtifact(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"api": "binary", "LICENSE": "license"})
+	info := &BuildInfo{Version: "1.2.3", BuildStamp: "2026-01-02T03:04:05Z"}
+
+	tests := []struct {
+		name    string
+		formats []string
+		want    []string
+		err     string
+	}{
+		{"default formats", nil, []string{"tar.gz", "zip"}, ""},
+		{"zip only", []string{ArchiveFormatZip}, []string{"zip"}, ""},
+		{"invalid format", []string{"rar"}, nil, "archive format `rar` invalid"},
+	}
+
+	for _, test := range tests {
+		pkg := &GoBuilderPackage{Dest: dir, BuildOS: "linux", BuildArch: "amd64", Reproducible: true,
+			Packaging: &Packaging{Formats: test.formats, Files: []string{filepath.Join(dir, "LICENSE")}}}
+		task := Task{Name: "api", Output: "api", Package: pkg}
+
+		archives, err := PackageArtifact(task, filepath.Join(dir, "api"), "00", info)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error %v want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(archives) != len(test.want) {
+			t.Errorf("%s: archives %v", test.name, archives)
+			continue
+		}
+
+		for i, a := range archives {
+			if want := filepath.Join(dir, "api-1.2.3-linux-amd64."+test.want[i]); a.Path != want {
+				t.Errorf("%s: archive %s want %s", test.name, a.Path, want)
+				continue
+			}
+			names := zipNames
+			if test.want[i] == ArchiveFormatTarGz {
+				names = tarNames
+			}
+			if got := strings.Join(names(t, a.Path), " "); got != "api-1.2.3-linux-amd64/api api-1.2.3-linux-amd64/LICENSE" {
+				t.Errorf("%s: %s entries %s", test.name, test.want[i], got)
+			}
+		}
+
+		// reproducible package give same archives
+		again, err := PackageArtifact(task, filepath.Join(dir, "api"), "00", info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range again {
+			if again[i].Sha256 != archives[i].Sha256 {
+				t.Errorf("%s: %s sha256 changed on repack", test.name, test.want[i])
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "api-1.2.3-linux-amd64.manifest.json")); err != nil {
+			t.Errorf("%s: manifest %v", test.name, err)
+		}
+	}
+}
+
+func TestArchiveFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"api-1.0.0-linux-amd64.tar.gz",
+		"api-v1.0.1-linux-amd64.zip",
+		"api-1.2.4-dev.3+abc1234-linux-amd64.manifest.json",
+		"api-admin-1.0.0-linux-amd64.tar.gz",
+		"api-admin-1.0.0-linux-amd64.manifest.json",
+		"api-1.0.0-linux-arm64.tar.gz",
+		"api-1.0.0-linux-amd64.tar.gz.sig",
+		"api",
+	}
+	for _, name := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pkg := &GoBuilderPackage{Dest: dir, BuildOS: "linux", BuildArch: "amd64", Packaging: &Packaging{}}
+	got, err := ArchiveFiles(Task{Name: "api", Output: "api", Package: pkg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range got {
+		got[i] = filepath.Base(got[i])
+	}
+	sort.Strings(got)
+
+	want := []string{
+		"api-1.0.0-linux-amd64.tar.gz",
+		"api-1.2.4-dev.3+abc1234-linux-amd64.manifest.json",
+		"api-v1.0.1-linux-amd64.zip",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("archives %v want %v", got, want)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArtifactMeta sidecar of built binary, deploy only run know which version it push
+type ArtifactMeta struct {
+	*BuildInfo
+	Name    string `json:"name"`
+	Output  string `json:"output"`
+	Package string `json:"package"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Sha256  string `json:"sha256"`
+	Size    int64  `json:"size"`
+}
+
+func MetaPath(binaryPath string) string {
+	return binaryPath + ".meta.json"
+}
+
+func WriteArtifactMeta(t Task, binaryPath string, info *BuildInfo, sha256 string, size int64) error {
+	goOS, goArch := t.Package.Target()
+	data, err := json.MarshalIndent(ArtifactMeta{
+		BuildInfo: info,
+		Name:      t.Name,
+		Output:    t.Output,
+		Package:   t.Package.Package,
+		OS:        goOS,
+		Arch:      goArch,
+		Sha256:    sha256,
+		Size:      size,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(MetaPath(binaryPath), append(data, '\n'), 0644)
+}
+
+func ReadArtifactMeta(path string) (*ArtifactMeta, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	meta := &ArtifactMeta{BuildInfo: &BuildInfo{}}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, errors.New("`" + path + "` " + err.Error())
+	}
+	return meta, nil
+}
+
+// Artifact binary ready to deploy with its build info
+type Artifact struct {
+	Path    string
+	Info    *BuildInfo
+	Sha256  string
+	Release bool // extracted from archived release, removed on Close
+
+	tempDir string
+}
+
+func (a *Artifact) Close() error {
+	if a.tempDir == "" {
+		return nil
+	}
+	return os.RemoveAll(a.tempDir)
+}
+
+// checkSha256 artifact must be the binary metadata describe
+func checkSha256(path, expect string) error {
+	signature, _, err := FileSignature(path)
+	if err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(signature); actual != expect {
+		return errors.New("`" + path + "` sha256 " + shortHex(actual) + " not match metadata " + shortHex(expect))
+	}
+	return nil
+}
+
+func shortHex(s string) string {
+	if len(s) > 12 {
+		return s[:12]
+	}
+	return s
+}
+
+// LocalArtifact binary of last build in dest
+func LocalArtifact(t Task) (*Artifact, error) {
+	binaryPath := filepath.Join(t.Package.Dest, t.Output)
+	meta, err := ReadArtifactMeta(MetaPath(binaryPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("artifact metadata `" + MetaPath(binaryPath) + "` not found, build `" + t.Output + "` first")
+		}
+		return nil, err
+	}
+	if err := checkSha256(binaryPath, meta.Sha256); err != nil {
+		return nil, err
+	}
+	return &Artifact{Path: binaryPath, Info: meta.BuildInfo, Sha256: meta.Sha256}, nil
+}
+
+// ReleaseArtifact binary extracted from archived release of version, checked with manifest
+func ReleaseArtifact(t Task, release string) (*Artifact, error) {
+	if t.Package.Packaging == nil {
+		return nil, errors.New("package `" + t.Name + "` without `packaging`, no archived release")
+	}
+	parsed, err := ParseVersion(release)
+	if err != nil {
+		return nil, err
+	}
+	version := parsed.String()
+
+	manifestPath := filepath.Join(t.Package.Dest, archiveBase(t, version)+".manifest.json")
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("release " + version + " of `" + t.Output + "` not found, `" + manifestPath + "` missing")
+		}
+		return nil, err
+	}
+	manifest := Manifest{BuildInfo: &BuildInfo{}}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.New("`" + manifestPath + "` " + err.Error())
+	}
+	if len(manifest.Archives) == 0 {
+		return nil, errors.New("`" + manifestPath + "` without archives")
+	}
+
+	tempDir, err := os.MkdirTemp("", "gobuilder-release-")
+	if err != nil {
+		return nil, err
+	}
+	artifact := &Artifact{
+		Path:    filepath.Join(tempDir, t.Output),
+		Info:    manifest.BuildInfo,
+		Sha256:  manifest.Binary.Sha256,
+		Release: true,
+		tempDir: tempDir,
+	}
+
+	archive := manifest.Archives[0]
+	archivePath := filepath.Join(t.Package.Dest, archive.Path)
+	if err := checkSha256(archivePath, archive.Sha256); err != nil {
+		_ = artifact.Close()
+		return nil, err
+	}
+
+	// binary on archive root under base dir
+	name := path.Join(strings.TrimSuffix(strings.TrimSuffix(archive.Path, "."+ArchiveFormatTarGz), "."+ArchiveFormatZip),
+		manifest.Binary.Path)
+	extract := extractTarGz
+	if strings.HasSuffix(archive.Path, "."+ArchiveFormatZip) {
+		extract = extractZip
+	}
+	if err := extract(archivePath, name, artifact.Path); err != nil {
+		_ = artifact.Close()
+		return nil, err
+	}
+
+	if err := checkSha256(artifact.Path, artifact.Sha256); err != nil {
+		_ = artifact.Close()
+		return nil, err
+	}
+	return artifact, nil
+}
+
+func writeExecutable(r io.Reader, target string) error {
+	o, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(o, r); err != nil {
+		_ = o.Close()
+		return err
+	}
+	return o.Close()
+}
+
+func extractTarGz(archivePath, name, target string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return errors.New("`" + name + "` not in `" + archivePath + "`")
+		}
+		if err != nil {
+			return err
+		}
+		if header.Name == name {
+			return writeExecutable(tr, target)
+		}
+	}
+}
+
+func extractZip(archivePath, name, target string) error {
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return writeExecutable(r, target)
+	}
+	return errors.New("`" + name + "` not in `" + archivePath + "`")
+}
@@ -1,171 +1,273 @@
 package main
 
 import (
-	"bytes"
-	"context"
 	"crypto/sha256"
-	"crypto/tls"
-	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"github.com/lucas-clemente/quic-go"
 	"gobuilder/log"
 	"gobuilder/quicpkg"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
-	"strconv"
-	"sync"
 	"time"
 )
 
-func GoBuild(name string, pkg *GoBuilderPackage) error {
+func GoBuild(name string, pkg *GoBuilderPackage) (*BuildInfo, error) {
+	info, err := NewBuildInfo(pkg)
+	if err != nil {
+		return nil, err
+	}
+	return info, GoBuildWith(name, pkg, info)
+}
+
+// GoBuildWith build package with given build info, rebuild of existing artifact
+func GoBuildWith(name string, pkg *GoBuilderPackage, info *BuildInfo) error {
 	// check project exists
 	if pkg.BuildMode == "host" {
-		return HostBuild(name, pkg)
+		return HostBuild(name, pkg, info)
 	} else if pkg.BuildMode == "docker" {
-		return DockerBuild(name, pkg)
+		return DockerBuild(name, pkg, info)
 	}
 
 	return errors.New("invalid `build-mode`")
 }
 
-func ProcessTask(wg *sync.WaitGroup, t Task) error {
-	defer wg.Done()
-	if err := GoBuild(t.Name, t.Package); err != nil {
-		return err
-	}
+// ProcessTask build task, deploy it when deploy set and package has `deploy`
+func ProcessTask(t Task, cache *BuildCache, deploy bool) (result TaskResult) {
+	start := time.Now()
+	result = NewTaskResult(t, TaskStatusFailed, nil)
+	defer func() {
+		result.Duration = time.Since(start)
+		if result.Err != nil {
+			log.Error("build package `"+t.Output+"` failed", result.Err)
+		}
+	}()
 
-	oldVersion := t.Package.Version.Clone()
+	// generated sources are inputs of fingerprint
+	if result.Err = RunHooks(HookBeforeBuild, t.Package.BeforeBuild, t, HookEnv{
+		Version: t.Package.BuildVersion().String(),
+	}); result.Err != nil {
+		return result
+	}
 
-	if t.Package.Version != nil {
-		t.Package.Version.Patch += 1
+	inputs, err := Fingerprint(t)
+	if err != nil {
+		result.Err = err
+		return result
 	}
+	// unchanged when built last with the version stamped now, hand edit of config version rebuild
+	if result.Fingerprint = VersionFingerprint(inputs, t.Package.BuildVersion()); cache.Unchanged(t, result.Fingerprint) {
+		if !deploy || len(t.Package.Deploy) == 0 || !cache.IsUndeployed(t) {
+			log.Ok("build skipped, inputs unchanged", t.Package.BuildVersion().String(), "-", t.Output)
+			result.Status = TaskStatusUnchanged
+			return result
+		}
 
-	// try push deploy
-	if t.Package.Deploy == "" {
-		log.Ok("build completed", oldVersion.String(), "->", t.Package.Version.String(), "-", t.Name)
-		return nil
+		// built before with --no-deploy, deploy that artifact instead of skip silently
+		log.Ok("build skipped, inputs unchanged, deploy last build", "-", t.Output)
+		if result.Deploys, result.Err = deployArtifact(t, ""); result.Err != nil {
+			return result
+		}
+		result.Status = TaskStatusDeployed
+		return result
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	if t.Package.PreBuild != nil {
+		if result.Gates, result.Err = RunGates(t); result.Err != nil {
+			return result
+		}
+	}
 
-	tlsCert, err := BuildConfig.GetTlsCert()
+	info, err := GoBuild(t.Output, t.Package)
 	if err != nil {
-		return err
+		result.Err = err
+		return result
 	}
+	result.Rebuilt = true
+
+	// version shared by all targets of package, bump once in BumpVersions
+	oldVersion := t.Package.BuildVersion()
+	newVersion, _ := t.Package.NextVersion()
+	result.NewVersion = newVersion.String()
+	// next build stamp bumped version, cache match it
+	result.Fingerprint = VersionFingerprint(inputs, newVersion)
 
-	pem, err := ioutil.ReadFile(BuildConfig.CA)
+	result.GitBranch, result.GitHash = info.GitBranch, info.GitHash
+
+	binaryPath := filepath.Join(t.Package.Dest, t.Output)
+	result.Artifact = binaryPath
+
+	signature, size, err := FileSignature(binaryPath)
 	if err != nil {
-		return err
+		result.Err = err
+		return result
 	}
-	caPool := x509.NewCertPool()
-	caPool.AppendCertsFromPEM(pem)
 
-	tlsConfig := &tls.Config{
-		RootCAs:      caPool,
-		Certificates: []tls.Certificate{tlsCert},
-		NextProtos:   []string{"gobuilder-quic"},
-		ServerName:   "gobuilder-quic",
+	if len(t.Package.AfterBuild) > 0 {
+		if result.Err = RunHooks(HookAfterBuild, t.Package.AfterBuild, t, HookEnv{
+			Version:  oldVersion.String(),
+			Artifact: binaryPath,
+			Sha256:   hex.EncodeToString(signature),
+		}); result.Err != nil {
+			return result
+		}
+		// hook like code signing may rewrite binary
+		if signature, size, err = FileSignature(binaryPath); err != nil {
+			result.Err = err
+			return result
+		}
 	}
+	result.Size = size
+	result.Sha256 = hex.EncodeToString(signature)
 
-	log.Debug("dial", t.Package.Deploy, "-", t.Name)
+	if result.Err = WriteArtifactMeta(t, binaryPath, info, result.Sha256, size); result.Err != nil {
+		return result
+	}
 
-	remote, err := quic.DialAddrContext(ctx, t.Package.Deploy, tlsConfig, nil)
-	if err != nil {
-		return err
+	if t.Package.Packaging != nil {
+		if result.Archives, result.Err = PackageArtifact(t, binaryPath, result.Sha256, info); result.Err != nil {
+			return result
+		}
 	}
 
-	// calc binary sha256
+	// try push deploy
+	if !deploy || len(t.Package.Deploy) == 0 {
+		log.Ok("build completed", oldVersion.String(), "->", newVersion.String(), "-", t.Output)
+		result.Status = TaskStatusBuilt
+		return result
+	}
+
+	result.Deploys, result.Err = DeployPackage(t.Output, t.Package, binaryPath, oldVersion.String(), info.GitRef())
+	RecordDeployResults(result.Deploys)
+	if result.Err != nil {
+		return result
+	}
+
+	if result.Err = RunHooks(HookAfterDeploy, t.Package.AfterDeploy, t, HookEnv{
+		Version:  oldVersion.String(),
+		Artifact: binaryPath,
+		Sha256:   result.Sha256,
+		Deploy:   BuildConfig.ResolveTargets(t.Package.Deploy),
+	}); result.Err != nil {
+		return result
+	}
+
+	log.Ok("deploy completed", oldVersion.String(), "->", newVersion.String(), "-", t.Output)
+
+	if t.Package.CleanAfterDeploy {
+		if result.Err = os.RemoveAll(binaryPath); result.Err != nil {
+			return result
+		}
+		_ = os.Remove(MetaPath(binaryPath))
+	}
+
+	result.Status = TaskStatusDeployed
+	return result
+}
 
-	binaryPath := filepath.Join(t.Package.Dest, t.Name)
+// BumpVersions apply NextVersion once for each package all targets succeeded and any rebuilt, return bumped versions
+func BumpVersions(tasks []Task, results []TaskResult) map[string]Version {
+	failed := make(map[string]bool)
+	rebuilt := make(map[string]bool)
+	for i, r := range results {
+		name := tasks[i].Name
+		if !r.Succeeded() {
+			failed[name] = true
+		}
+		if r.Rebuilt && r.Succeeded() {
+			rebuilt[name] = true
+		}
+	}
+
+	bumped := make(map[string]Version)
+	for name := range rebuilt {
+		pkg := BuildConfig.Packages[name]
+		if failed[name] || pkg.Version == nil {
+			continue
+		}
+		if next, hold := pkg.NextVersion(); hold == "" {
+			*pkg.Version = next
+			bumped[name] = next.Clone()
+		}
+	}
+	return bumped
+}
+
+func UploadPackage(remote quic.Connection, name, binaryPath, version, gitHash string) (*quicpkg.PacketPackageReplaceResponse, error) {
+	// calc binary sha256
 
-	log.Debug("read binary", t.Package.Dest, "-", t.Name)
+	log.Debug("read binary", binaryPath, "-", name)
 
 	o, err := os.Open(binaryPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer o.Close()
 
-	fileBuffer := bytes.NewBuffer([]byte{})
-	hashChunk := make([]byte, 1024)
 	hashFunc := sha256.New()
-	for {
-		chunkLen, err := o.Read(hashChunk)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return err
-		}
-		chunk := hashChunk[:chunkLen]
-		fileBuffer.Write(chunk)
-		hashFunc.Write(chunk)
+	binarySize, err := io.Copy(hashFunc, o)
+	if err != nil {
+		return nil, err
 	}
 	hashSum := hashFunc.Sum(nil)
 
-	stream, err := remote.OpenStream()
-	if err != nil {
-		return err
+	if _, err := o.Seek(0, io.SeekStart); err != nil {
+		return nil, err
 	}
 
-	fileBytes := fileBuffer.Bytes()
-
+	packageName, err := quicpkg.NewPackageName(name)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := quicpkg.NewBytes[uint8](hashSum)
+	if err != nil {
+		return nil, err
+	}
+	packageVersion, err := quicpkg.NewString[uint8](version)
+	if err != nil {
+		return nil, errors.New("version " + err.Error())
+	}
+	packageGitHash, err := quicpkg.NewString[uint8](gitHash)
+	if err != nil {
+		return nil, errors.New("git hash " + err.Error())
+	}
 	request := quicpkg.PacketPackageReplace{
-		PacketPackageName: quicpkg.PacketPackageName{
-			Package: quicpkg.Data[uint16, string]{
-				Size: uint16(len(t.Name)),
-				Data: t.Name,
-			},
-		},
-		PacketPackage: quicpkg.PacketPackage{
-			Signature: quicpkg.Data[uint8, []byte]{
-				Size: uint8(len(hashSum)),
-				Data: hashSum,
-			},
-			Data: quicpkg.Data[uint64, []byte]{
-				Size: uint64(len(fileBytes)),
-				Data: fileBytes,
-			},
+		PacketPackageName: packageName,
+		PacketPackageHeader: quicpkg.PacketPackageHeader{
+			Signature: signature,
+			Size:      uint64(binarySize),
 		},
+		Version: packageVersion,
+		GitHash: packageGitHash,
 	}
 
-	if err := request.WriteWithOp(stream); err != nil {
-		return err
+	stream, err := OpenRequest(remote, quicpkg.OperationPacketReplace)
+	if err != nil {
+		return nil, err
 	}
 
-	var op byte
-	if err := quicpkg.Read(stream, &op); err != nil {
-		return err
+	if err := request.WriteWithOp(stream); err != nil {
+		return nil, err
 	}
 
-	if op == quicpkg.OperationPacketError {
-		var pktError quicpkg.PacketErrorResponse
-		if err := pktError.Read(stream); err != nil {
-			return err
-		}
-		log.Error("`"+t.Name+"` deploy failed",
-			"["+strconv.FormatUint(uint64(pktError.ErrCode), 10)+"]",
-			pktError.ErrMessage.Data)
-		return nil
+	// wait server accept then upload chunks
+	if _, err := quicpkg.ReadOp(stream); err != nil {
+		return nil, err
 	}
 
-	var response quicpkg.PacketPackageReplaceResponse
-	if err := response.Read(stream); err != nil {
-		return err
+	if _, err := quicpkg.WriteChunks(stream, o); err != nil {
+		return nil, err
 	}
 
-	log.Debug(t.Name, "-", t.Package.Package, "-", "bstdout", response.BeforeStdout.Data, "astdout", response.AfterStdout.Data)
-
-	log.Ok("deploy completed", oldVersion.String(), "->", t.Package.Version.String(), "-", t.Name)
+	if _, err := quicpkg.ReadOp(stream); err != nil {
+		return nil, err
+	}
 
-	if t.Package.CleanAfterDeploy {
-		if err := os.RemoveAll(binaryPath); err != nil {
-			return err
-		}
+	var response quicpkg.PacketPackageReplaceResponse
+	if err := response.Read(stream); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return &response, nil
 }
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gobuilder/env"
+	"gobuilder/log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// BuildFlags flags of build only, also accepted before the command for the default build
+type BuildFlags struct {
+	NoDeploy     bool
+	FailFast     bool
+	Force        bool
+	ReportFormat string
+	ReportPath   string
+}
+
+func (b *BuildFlags) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&b.NoDeploy, "no-deploy", false, "build only, deploy later with `gobuilder deploy`")
+	fs.BoolVar(&b.FailFast, "fail-fast", false, "stop at first failed package, queued packages are canceled")
+	fs.BoolVar(&b.Force, "force", false, "build packages even inputs unchanged")
+	fs.StringVar(&b.ReportFormat, "report", "", "write build report in `format` json or junit")
+	fs.StringVar(&b.ReportPath, "report-file", "", "report output path, default gobuilder-report.json or .xml")
+}
+
+func BuildHandle(args []string) error {
+	fs := NewFlagSet("build")
+	var flags BuildFlags
+	flags.Register(fs)
+	names, err := ParseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+
+	if flags.ReportFormat != "" && flags.ReportFormat != ReportFormatJSON && flags.ReportFormat != ReportFormatJUnit {
+		return errors.New("report format `" + flags.ReportFormat + "` invalid, json or junit")
+	}
+	if flags.ReportPath == "" {
+		flags.ReportPath = DefaultReportPath(flags.ReportFormat)
+	}
+
+	parallel := 1
+	if BuildConfig.Parallel > 1 {
+		parallel = BuildConfig.Parallel
+	}
+
+	start := time.Now()
+
+	// versions stamped by this build stay locked until saved, tasks share versions read under lock
+	saveState := BuildConfig.AutoUpgrade && !CLI.NoVersionBump && !CLI.DryRun
+	if saveState {
+		unlock, err := LockVersionState(StatePath(CLI.EnvConfigPath()))
+		if err != nil {
+			return errors.New("lock version state failed " + err.Error())
+		}
+		defer unlock()
+	}
+
+	tasks, err := SelectTasks(names)
+	if err != nil {
+		return err
+	}
+
+	graph, err := NewTaskGraph(tasks)
+	if err != nil {
+		return errors.New("build graph invalid " + err.Error())
+	}
+
+	// dry run same as plan
+	if CLI.DryRun {
+		return PrintPlan(os.Stdout, graph.Tasks, !flags.NoDeploy)
+	}
+
+	cache, err := LoadBuildCache(CLI.EnvConfigPath()+".cache", flags.Force)
+	if err != nil {
+		return errors.New("read build cache failed " + err.Error())
+	}
+
+	var failed error
+	results := graph.Run(parallel, flags.FailFast, func(t Task) TaskResult {
+		return ProcessTask(t, cache, !flags.NoDeploy)
+	})
+	bumped := BumpVersions(graph.Tasks, results)
+	if saveState {
+		if err := SaveVersionState(StatePath(CLI.EnvConfigPath()), bumped); err != nil {
+			failed = errors.New("write version state failed " + err.Error())
+		}
+	}
+
+	cache.Update(results)
+	if err := cache.Save(); err != nil {
+		log.Error("write build cache failed", err)
+	}
+
+	if err := WriteChecksums(results); err != nil {
+		log.Error("write "+ChecksumFile+" failed", err)
+	}
+	for _, r := range results {
+		if r.Status == TaskStatusSkipped {
+			log.Warn("skip package `"+r.Output+"`", r.Err)
+		}
+	}
+
+	if err := PrintDeploySummary(); err != nil {
+		log.Error("print deploy summary failed", err)
+	}
+
+	if err := PrintBuildSummary(results); err != nil {
+		log.Error("print build summary failed", err)
+	}
+
+	if flags.ReportFormat != "" {
+		if err := WriteReport(flags.ReportFormat, flags.ReportPath, NewReport(start, results)); err != nil {
+			log.Error("write report failed", err)
+		} else {
+			log.Ok("report written", flags.ReportPath)
+		}
+	}
+
+	if AnyTaskFailed(results) {
+		count := 0
+		for _, r := range results {
+			if r.Failed() {
+				count++
+			}
+		}
+		return errors.New(strconv.Itoa(count) + " of " + strconv.Itoa(len(results)) + " packages failed")
+	}
+
+	return failed
+}
+
+// DeployHandle upload artifact of last build in dest or archived release without rebuild
+func DeployHandle(args []string) error {
+	fs := NewFlagSet("deploy")
+	release := fs.String("release", "", "deploy archived release of `version` instead of dest binary")
+	names, err := ParseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	if names, err = deployPackages(names); err != nil {
+		return err
+	}
+
+	var tasks []Task
+	for _, name := range names {
+		expanded, err := BuildConfig.Packages[name].Tasks(name)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, expanded...)
+	}
+
+	failed := 0
+	for _, t := range tasks {
+		if _, err := deployArtifact(t, *release); err != nil {
+			log.Error("deploy package `"+t.Output+"` failed", err)
+			failed++
+		}
+	}
+
+	if err := PrintDeploySummary(); err != nil {
+		log.Error("print deploy summary failed", err)
+	}
+
+	if failed > 0 {
+		return errors.New(strconv.Itoa(failed) + " of " + strconv.Itoa(len(tasks)) + " packages failed")
+	}
+	return nil
+}
+
+// deployArtifact deploy built or released artifact of task, without build
+func deployArtifact(t Task, release string) ([]DeployResult, error) {
+	var artifact *Artifact
+	var err error
+	if release == "" {
+		artifact, err = LocalArtifact(t)
+	} else {
+		artifact, err = ReleaseArtifact(t, release)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer artifact.Close()
+
+	if CLI.DryRun {
+		_, _ = fmt.Printf("%s %s %s sha256 %s\n", t.Output, artifact.Info.Version, artifact.Path, shortHex(artifact.Sha256))
+		for _, step := range planDeploy(t, artifact.Path) {
+			_, _ = fmt.Println("  deploy:   ", step)
+		}
+		return nil, nil
+	}
+
+	results, err := DeployPackage(t.Output, t.Package, artifact.Path, artifact.Info.Version, artifact.Info.GitRef())
+	RecordDeployResults(results)
+	if err != nil {
+		return results, err
+	}
+
+	if err := RunHooks(HookAfterDeploy, t.Package.AfterDeploy, t, HookEnv{
+		Version:  artifact.Info.Version,
+		Artifact: artifact.Path,
+		Sha256:   artifact.Sha256,
+		Deploy:   BuildConfig.ResolveTargets(t.Package.Deploy),
+	}); err != nil {
+		return results, err
+	}
+
+	log.Ok("deploy completed", artifact.Info.Version, "-", t.Output)
+
+	if t.Package.CleanAfterDeploy && !artifact.Release {
+		if err := os.RemoveAll(artifact.Path); err != nil {
+			return results, err
+		}
+		_ = os.Remove(MetaPath(artifact.Path))
+	}
+	return results, nil
+}
+
+// deployPackages named packages must have `deploy`, all packages with `deploy` when names empty
+func deployPackages(names []string) ([]string, error) {
+	if len(names) == 0 {
+		for name, pkg := range BuildConfig.Packages {
+			if len(pkg.Deploy) > 0 {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, errors.New("no package with `deploy`")
+		}
+		sort.Strings(names)
+		return names, nil
+	}
+
+	for _, name := range names {
+		pkg, ok := BuildConfig.Packages[name]
+		if !ok {
+			return nil, NotFoundError(name)
+		}
+		if len(pkg.Deploy) == 0 {
+			return nil, errors.New("package `" + name + "` without `deploy`")
+		}
+	}
+	return names, nil
+}
+
+// ListHandle configured packages with targets, version and dependencies
+func ListHandle(args []string) error {
+	fs := NewFlagSet("list")
+	names, err := ParseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		for name := range BuildConfig.Packages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tOUTPUT\tTARGET\tMODE\tVERSION\tDEPLOY\tDEPENDS-ON")
+
+	for _, name := range names {
+		pkg, ok := BuildConfig.Packages[name]
+		if !ok {
+			return NotFoundError(name)
+		}
+		if err := pkg.ResolveVersion(); err != nil {
+			return errors.New("package `" + name + "` " + err.Error())
+		}
+		tasks, err := pkg.Tasks(name)
+		if err != nil {
+			return err
+		}
+
+		deploy := strings.Join(BuildConfig.ResolveTargets(pkg.Deploy), ",")
+		if deploy == "" {
+			deploy = "-"
+		}
+		depends := strings.Join(pkg.DependsOn, ",")
+		if depends == "" {
+			depends = "-"
+		}
+
+		for _, t := range tasks {
+			goos, goarch := t.Package.Target()
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s/%s\t%s\t%s\t%s\t%s\n", name, t.Output, goos, goarch,
+				pkg.BuildMode, pkg.BuildVersion().String(), deploy, depends)
+		}
+	}
+
+	return w.Flush()
+}
+
+// CleanHandle remove built binaries, archives and build cache of packages
+func CleanHandle(args []string) error {
+	fs := NewFlagSet("clean")
+	names, err := ParseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+
+	all := len(names) == 0
+	if all {
+		for name := range BuildConfig.Packages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var paths []string
+	for _, name := range names {
+		pkg, ok := BuildConfig.Packages[name]
+		if !ok {
+			return NotFoundError(name)
+		}
+		tasks, err := pkg.Tasks(name)
+		if err != nil {
+			return err
+		}
+		for _, t := range tasks {
+			binaryPath := filepath.Join(pkg.Dest, t.Output)
+			paths = append(paths, binaryPath, MetaPath(binaryPath))
+			archives, err := ArchiveFiles(t)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, archives...)
+		}
+	}
+
+	for _, path := range paths {
+		if CLI.DryRun {
+			log.Log("would remove", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		log.Ok("removed", path)
+	}
+
+	// binary removed, cache entry of cleaned package never match again
+	if all {
+		cachePath := CLI.EnvConfigPath() + ".cache"
+		if CLI.DryRun {
+			log.Log("would remove", cachePath)
+			return nil
+		}
+		if err := os.Remove(cachePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// VersionHandle print gobuilder version, no config required
+func VersionHandle(args []string) error {
+	version := env.Version
+	if version == "" {
+		version = "dev"
+	}
+
+	fmt.Println("gobuilder", version)
+	if env.BuildStamp != "" {
+		fmt.Println("build stamp:", env.BuildStamp)
+	}
+	if env.BuildTool != "" {
+		fmt.Println("build tool:", env.BuildTool)
+	}
+	if env.GitHash != "" {
+		fmt.Println("git hash:", env.GitHash)
+	}
+	fmt.Println("go:", runtime.Version(), runtime.GOOS+"/"+runtime.GOARCH)
+	return nil
+}
+
+const initConfigTemplate = `# generated by gobuilder init
+version: %s
+parallel: %d
+auto-upgrade: true
+packages:
+%s`
+
+// InitHandle write starter config with main packages of module, never overwrite
+func InitHandle(args []string) error {
+	fs := NewFlagSet("init")
+	if _, err := ParseArgs(fs, args); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(CLI.ConfigPath); err == nil {
+		return errors.New("`" + CLI.ConfigPath + "` already exists")
+	}
+
+	cmd := NewCommand("go", "list", "-f", "{{if eq .Name \"main\"}}{{.ImportPath}}{{end}}", "./...")
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := cmd.Wait(); err != nil {
+		return errors.New("list main packages failed " + err.Error())
+	}
+
+	var packages strings.Builder
+	for _, importPath := range strings.Fields(string(cmd.Stdout())) {
+		name := filepath.Base(importPath)
+		_, _ = fmt.Fprintf(&packages, `  %s:
+    package: %s
+    build-mode: host
+    build-os: %s
+    build-arch: %s
+    version:
+      major: 0
+      minor: 1
+      patch: 0
+    dest: bin
+`, name, importPath, runtime.GOOS, runtime.GOARCH)
+	}
+	if packages.Len() == 0 {
+		return errors.New("no main package found in module")
+	}
+
+	goVersion := strings.TrimPrefix(runtime.Version(), "go")
+	config := fmt.Sprintf(initConfigTemplate, goVersion, runtime.NumCPU(), packages.String())
+
+	if CLI.DryRun {
+		fmt.Print(config)
+		return nil
+	}
+	if err := os.WriteFile(CLI.ConfigPath, []byte(config), 0644); err != nil {
+		return err
+	}
+
+	log.Ok("config written", CLI.ConfigPath)
+	return nil
+}
@@ -2,35 +2,140 @@ package main
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
+	"gopkg.in/yaml.v3"
 	"io/ioutil"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Version struct {
-	Major int `json:"major"`
-	Minor int `json:"minor"`
-	Patch int `json:"patch"`
+	Major      int    `json:"major"`
+	Minor      int    `json:"minor"`
+	Patch      int    `json:"patch"`
+	Prerelease string `json:"prerelease,omitempty" yaml:"prerelease,omitempty"` // like rc.1
+	Metadata   string `json:"metadata,omitempty" yaml:"metadata,omitempty"`     // build metadata after `+`
 }
 
 func (v Version) Clone() Version {
-	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch, Prerelease: v.Prerelease, Metadata: v.Metadata}
+}
+
+// ParseVersion parse semver major.minor.patch[-prerelease][+metadata], `v` prefix allowed
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	invalid := errors.New("version `" + s + "` invalid, expect major.minor.patch[-prerelease][+metadata]")
+
+	core := strings.TrimPrefix(s, "v")
+	if i := strings.Index(core, "+"); i >= 0 {
+		v.Metadata = core[i+1:]
+		core = core[:i]
+		if v.Metadata == "" {
+			return v, invalid
+		}
+	}
+	if i := strings.Index(core, "-"); i >= 0 {
+		v.Prerelease = core[i+1:]
+		core = core[:i]
+		if v.Prerelease == "" {
+			return v, invalid
+		}
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, invalid
+	}
+	for i, p := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return v, invalid
+		}
+		*p = n
+	}
+	return v, nil
 }
 
 func (v Version) String() string {
-	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Metadata != "" {
+		s += "+" + v.Metadata
+	}
+	return s
 }
 
 type GoBuilderPackage struct {
-	Package          string   `yaml:"package"`
-	VerbosePackage   string   `yaml:"verbose-package"`
-	BuildFlag        []string `yaml:"build-flag,omitempty"` // suffix flag
-	BuildMode        string   `yaml:"build-mode"`           // host or docker
-	BuildOS          string   `yaml:"build-os,omitempty"`   // darwin or linux or windows
-	BuildArch        string   `yaml:"build-arch,omitempty"` // arm64 or amd64 or ...
-	Version          *Version `yaml:"version,omitempty"`
-	Dest             string   `yaml:"dest,omitempty"`
-	Deploy           string   `yaml:"deploy,omitempty"` // remote quic path
-	CleanAfterDeploy bool     `yaml:"clean-after-deploy,omitempty"`
+	Package          string     `yaml:"package"`
+	VerbosePackage   string     `yaml:"verbose-package"`
+	BuildFlag        []string   `yaml:"build-flag,omitempty"` // suffix flag
+	BuildMode        string     `yaml:"build-mode"`           // host or docker
+	BuildOS          string     `yaml:"build-os,omitempty"`   // darwin or linux or windows
+	BuildArch        string     `yaml:"build-arch,omitempty"` // arm64 or amd64 or ...
+	Matrix           []string   `yaml:"targets,omitempty"`    // os/arch list, each one build as a task
+	Output           string     `yaml:"output,omitempty"`     // binary name template like {name}-{os}-{arch}{ext}
+	Version          *Version   `yaml:"version,omitempty"`
+	VersionSource    string     `yaml:"version-source,omitempty"` // config or git-tag or conventional
+	Dest             string     `yaml:"dest,omitempty"`
+	Packaging        *Packaging `yaml:"packaging,omitempty"` // release archives, checksums and manifest
+	Deploy           Targets    `yaml:"deploy,omitempty"`    // remote quic address or deploy group
+	Canary           *Canary    `yaml:"canary,omitempty"`
+	CleanAfterDeploy bool       `yaml:"clean-after-deploy,omitempty"`
+	SkipUnchanged    bool       `yaml:"skip-unchanged,omitempty"` // skip upload when remote sha256 same
+	DependsOn        []string   `yaml:"depends-on,omitempty"`     // packages build before this one
+	Reproducible     bool       `yaml:"reproducible,omitempty"`   // same commit build byte for byte same binary
+	PreBuild         *PreBuild  `yaml:"pre-build,omitempty"`      // go test, go vet and lint gates before build
+	BeforeBuild      []string   `yaml:"before-build,omitempty"`   // commands before build like go generate ./...
+	AfterBuild       []string   `yaml:"after-build,omitempty"`    // commands after build like code signing
+	AfterDeploy      []string   `yaml:"after-deploy,omitempty"`   // commands after every target deployed
+
+	resolvedVersion *Version // version of git version source
+}
+
+// Target GOOS and GOARCH of binary, host platform when not set
+func (p *GoBuilderPackage) Target() (string, string) {
+	goOS, goArch := p.BuildOS, p.BuildArch
+	if goOS == "" {
+		goOS = runtime.GOOS
+	}
+	if goArch == "" {
+		goArch = runtime.GOARCH
+	}
+	return goOS, goArch
+}
+
+// Canary deploy first hosts then wait before deploy the rest
+type Canary struct {
+	Hosts int           `yaml:"hosts"`
+	Wait  time.Duration `yaml:"wait,omitempty"`
+}
+
+// Targets accept single address or list
+type Targets []string
+
+func (t *Targets) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = Targets{value.Value}
+		return nil
+	}
+	var targets []string
+	if err := value.Decode(&targets); err != nil {
+		return err
+	}
+	*t = targets
+	return nil
+}
+
+func (t Targets) MarshalYAML() (interface{}, error) {
+	if len(t) == 1 {
+		return t[0], nil
+	}
+	return []string(t), nil
 }
 
 type GoBuilderConfig struct {
@@ -42,6 +147,29 @@ type GoBuilderConfig struct {
 	CA          string                       `yaml:"ca,omitempty"`
 	Cert        string                       `yaml:"cert,omitempty"`
 	Key         string                       `yaml:"key,omitempty"`
+	ServerName  string                       `yaml:"server-name,omitempty"` // server cert SAN, default gobuilder-quic
+	// deploy group name to addresses, usable in package `deploy`
+	DeployGroups   map[string][]string `yaml:"deploy-groups,omitempty"`
+	DeployParallel int                 `yaml:"deploy-parallel,omitempty"`
+}
+
+// ResolveTargets expand deploy groups and remove duplicate address
+func (c GoBuilderConfig) ResolveTargets(targets Targets) []string {
+	var resolved []string
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		addresses, ok := c.DeployGroups[target]
+		if !ok {
+			addresses = []string{target}
+		}
+		for _, address := range addresses {
+			if !seen[address] {
+				seen[address] = true
+				resolved = append(resolved, address)
+			}
+		}
+	}
+	return resolved
 }
 
 func (c GoBuilderConfig) GetTlsCert() (tls.Certificate, error) {
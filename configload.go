@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type configTree = map[string]any
+
+// mergeTree deep merge overlay onto base, maps merge by key, lists and scalars replaced
+func mergeTree(base, overlay configTree) configTree {
+	merged := make(configTree, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		baseMap, baseOk := merged[k].(configTree)
+		overlayMap, overlayOk := v.(configTree)
+		if baseOk && overlayOk {
+			merged[k] = mergeTree(baseMap, overlayMap)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func stringList(value any, key string) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("`" + key + "` expect string list")
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+	return nil, errors.New("`" + key + "` expect string or string list")
+}
+
+// readConfigTree read yaml file with `include` fragments merged under it, include path relative to file
+func readConfigTree(path string, visiting map[string]bool) (configTree, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if visiting[abs] {
+		return nil, errors.New("`" + path + "` include cycle")
+	}
+	visiting[abs] = true
+	defer delete(visiting, abs)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tree := make(configTree)
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, errors.New("`" + path + "` " + err.Error())
+	}
+
+	includes, err := stringList(tree["include"], "include")
+	if err != nil {
+		return nil, errors.New("`" + path + "` " + err.Error())
+	}
+	delete(tree, "include")
+
+	merged := make(configTree)
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		matches, err := filepath.Glob(include)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, errors.New("`" + path + "` include `" + include + "` not found")
+		}
+		sort.Strings(matches)
+
+		for _, m := range matches {
+			fragment, err := readConfigTree(m, visiting)
+			if err != nil {
+				return nil, err
+			}
+			merged = mergeTree(merged, fragment)
+		}
+	}
+
+	return mergeTree(merged, tree), nil
+}
+
+// resolvePackages apply `defaults` and `extends` chain to every package
+func resolvePackages(tree configTree) error {
+	defaults, _ := tree["defaults"].(configTree)
+	delete(tree, "defaults")
+
+	packages, _ := tree["packages"].(configTree)
+	resolved := make(map[string]configTree)
+	visiting := make(map[string]bool)
+
+	var resolve func(name string, chain []string) (configTree, error)
+	resolve = func(name string, chain []string) (configTree, error) {
+		if pkg, ok := resolved[name]; ok {
+			return pkg, nil
+		}
+		if visiting[name] {
+			return nil, errors.New("package extends cycle " + strings.Join(append(chain, name), " -> "))
+		}
+
+		raw, ok := packages[name]
+		if !ok {
+			return nil, errors.New("package `" + chain[len(chain)-1] + "` extends unknown package `" + name + "`")
+		}
+		pkg, ok := raw.(configTree)
+		if !ok {
+			return nil, errors.New("package `" + name + "` expect mapping")
+		}
+
+		visiting[name] = true
+		defer delete(visiting, name)
+
+		// parent already carry defaults
+		base := defaults
+		if parent, ok := pkg["extends"]; ok {
+			parentName, ok := parent.(string)
+			if !ok {
+				return nil, errors.New("package `" + name + "` `extends` expect package name")
+			}
+			var err error
+			if base, err = resolve(parentName, append(chain, name)); err != nil {
+				return nil, err
+			}
+		}
+		if base != nil {
+			pkg = mergeTree(base, pkg)
+		}
+		delete(pkg, "extends")
+
+		resolved[name] = pkg
+		return pkg, nil
+	}
+
+	for name := range packages {
+		pkg, err := resolve(name, nil)
+		if err != nil {
+			return err
+		}
+		packages[name] = pkg
+	}
+
+	return nil
+}
+
+// LoadConfig read config file, `GOBUILDER_ENV` file overlay on it when env set
+func LoadConfig(path, env string, config *GoBuilderConfig) error {
+	tree, err := readConfigTree(path, make(map[string]bool))
+	if err != nil && !(env != "" && os.IsNotExist(err)) {
+		return err
+	}
+
+	if env != "" {
+		overlay, err := readConfigTree(path+"."+env, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+		tree = mergeTree(tree, overlay)
+	}
+
+	if err := resolvePackages(tree); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, config)
+}
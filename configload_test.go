@@ -0,0 +1,251 @@
+package main
+
+import (
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseTree(t *testing.T, data string) configTree {
+	t.Helper()
+	tree := make(configTree)
+	if err := yaml.Unmarshal([]byte(data), &tree); err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMergeTree(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+	}{
+		{"scalar replaced", "parallel: 2\nverbose: true", "parallel: 8", "parallel: 8\nverbose: true"},
+		{"new key added", "parallel: 2", "ca: root.pem", "parallel: 2\nca: root.pem"},
+		{"map merged by key", "packages: {a: {dest: bin, build-mode: host}}", "packages: {a: {dest: out}, b: {dest: bin}}",
+			"packages: {a: {dest: out, build-mode: host}, b: {dest: bin}}"},
+		{"list replaced", "packages: {a: {deploy: [h1, h2]}}", "packages: {a: {deploy: [h3]}}", "packages: {a: {deploy: [h3]}}"},
+		{"map replaced by scalar", "packages: {a: {dest: bin}}", "packages: none", "packages: none"},
+	}
+
+	for _, test := range tests {
+		base := parseTree(t, test.base)
+		before := parseTree(t, test.base)
+		merged := mergeTree(base, parseTree(t, test.overlay))
+		if want := parseTree(t, test.want); !reflect.DeepEqual(merged, want) {
+			t.Errorf("%s: merged %v want %v", test.name, merged, want)
+		}
+		if !reflect.DeepEqual(base, before) {
+			t.Errorf("%s: base modified %v", test.name, base)
+		}
+	}
+}
+
+func TestResolvePackages(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string // packages after resolve
+		err    string
+	}{
+		{
+			name:   "defaults applied",
+			config: "defaults: {build-mode: host, dest: bin}\npackages: {a: {package: x/a}, b: {package: x/b, dest: out}}",
+			want:   "{a: {package: x/a, build-mode: host, dest: bin}, b: {package: x/b, build-mode: host, dest: out}}",
+		},
+		{
+			name: "extends chain",
+			config: `defaults: {build-mode: host}
+packages:
+  base: {dest: bin, deploy: [h1]}
+  api: {extends: base, package: x/api}
+  api-canary: {extends: api, deploy: [h2]}`,
+			want: `base: {build-mode: host, dest: bin, deploy: [h1]}
+api: {build-mode: host, dest: bin, deploy: [h1], package: x/api}
+api-canary: {build-mode: host, dest: bin, deploy: [h2], package: x/api}`,
+		},
+		{
+			name:   "extends cycle",
+			config: "packages: {a: {extends: b}, b: {extends: c}, c: {extends: a}}",
+			err:    "package extends cycle",
+		},
+		{
+			name:   "extends self",
+			config: "packages: {a: {extends: a}}",
+			err:    "package extends cycle a -> a",
+		},
+		{
+			name:   "unknown parent",
+			config: "packages: {a: {extends: nope}}",
+			err:    "package `a` extends unknown package `nope`",
+		},
+		{
+			name:   "parent not a name",
+			config: "packages: {a: {extends: [b]}, b: {}}",
+			err:    "`extends` expect package name",
+		},
+	}
+
+	for _, test := range tests {
+		tree := parseTree(t, test.config)
+		err := resolvePackages(tree)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error %v want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if _, ok := tree["defaults"]; ok {
+			t.Errorf("%s: defaults left in tree", test.name)
+		}
+		if want := parseTree(t, test.want); !reflect.DeepEqual(tree["packages"], want) {
+			t.Errorf("%s: packages %v want %v", test.name, tree["packages"], want)
+		}
+	}
+}
+
+func TestReadConfigTree(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+		err   string
+	}{
+		{
+			name: "include glob in order, file win",
+			files: map[string]string{
+				".gobuilder":           "include: conf.d/*.yaml\nparallel: 4",
+				"conf.d/10-base.yaml":  "parallel: 1\npackages: {a: {dest: bin}}",
+				"conf.d/20-extra.yaml": "packages: {a: {dest: out}, b: {dest: bin}}",
+			},
+			want: "parallel: 4\npackages: {a: {dest: out}, b: {dest: bin}}",
+		},
+		{
+			name: "nested include relative to fragment",
+			files: map[string]string{
+				".gobuilder":       "include: [conf/main.yaml]",
+				"conf/main.yaml":   "include: shared.yaml\nverbose: true",
+				"conf/shared.yaml": "ca: root.pem",
+			},
+			want: "verbose: true\nca: root.pem",
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				".gobuilder": "include: a.yaml",
+				"a.yaml":     "include: b.yaml",
+				"b.yaml":     "include: a.yaml",
+			},
+			err: "include cycle",
+		},
+		{
+			name:  "include not found",
+			files: map[string]string{".gobuilder": "include: missing/*.yaml"},
+			err:   "not found",
+		},
+		{
+			name:  "include not a list",
+			files: map[string]string{".gobuilder": "include: {a: b}"},
+			err:   "`include` expect string or string list",
+		},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		writeFiles(t, dir, test.files)
+
+		tree, err := readConfigTree(filepath.Join(dir, ".gobuilder"), make(map[string]bool))
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error %v want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if want := parseTree(t, test.want); !reflect.DeepEqual(tree, want) {
+			t.Errorf("%s: tree %v want %v", test.name, tree, want)
+		}
+	}
+}
+
+func TestLoadConfigEnv(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".gobuilder": `parallel: 2
+defaults: {build-mode: host, dest: bin}
+packages:
+  api: {package: x/api, deploy: [staging:2031]}`,
+		".gobuilder.prod": `parallel: 8
+packages:
+  api: {deploy: [prod-1:2031, prod-2:2031]}`,
+		"only/.gobuilder.prod": "packages: {api: {package: x/api}}",
+	})
+	path := filepath.Join(dir, ".gobuilder")
+
+	tests := []struct {
+		name     string
+		path     string
+		env      string
+		parallel int
+		deploy   []string
+		err      bool
+	}{
+		{"base only", path, "", 2, []string{"staging:2031"}, false},
+		{"env overlay", path, "prod", 8, []string{"prod-1:2031", "prod-2:2031"}, false},
+		{"env without base", filepath.Join(dir, "only", ".gobuilder"), "prod", 0, nil, false},
+		{"env overlay missing", path, "dev", 0, nil, true},
+		{"config missing", filepath.Join(dir, "missing"), "", 0, nil, true},
+	}
+
+	for _, test := range tests {
+		var config GoBuilderConfig
+		err := LoadConfig(test.path, test.env, &config)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expect error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		api := config.Packages["api"]
+		if config.Parallel != test.parallel || api == nil || api.Package != "x/api" {
+			t.Errorf("%s: parallel %d api %+v", test.name, config.Parallel, api)
+			continue
+		}
+		if deploy := strings.Join(api.Deploy, ","); deploy != strings.Join(test.deploy, ",") {
+			t.Errorf("%s: deploy %s want %v", test.name, deploy, test.deploy)
+		}
+		if test.path == path && (api.BuildMode != "host" || api.Dest != "bin") {
+			t.Errorf("%s: defaults not applied %+v", test.name, api)
+		}
+	}
+}
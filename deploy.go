@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gobuilder/log"
+	"gobuilder/quicpkg"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	DeployStatusDeployed   = "deployed"
+	DeployStatusRolledBack = "rolled-back"
+	DeployStatusFailed     = "failed"
+	DeployStatusSkipped    = "skipped"
+)
+
+type DeployResult struct {
+	Package  string
+	Target   string
+	Status   string
+	Duration time.Duration
+	Err      error
+}
+
+var (
+	deployResultsLock sync.Mutex
+	deployResults     []DeployResult
+)
+
+func RecordDeployResults(results []DeployResult) {
+	deployResultsLock.Lock()
+	defer deployResultsLock.Unlock()
+	deployResults = append(deployResults, results...)
+}
+
+func PrintDeploySummary() error {
+	deployResultsLock.Lock()
+	defer deployResultsLock.Unlock()
+
+	if len(deployResults) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PACKAGE\tTARGET\tRESULT\tDURATION\tERROR")
+	for _, r := range deployResults {
+		var errMessage string
+		if r.Err != nil {
+			errMessage = strings.SplitN(r.Err.Error(), "\n", 2)[0]
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			r.Package, r.Target, r.Status, r.Duration.Round(time.Millisecond), errMessage)
+	}
+	return w.Flush()
+}
+
+func deployFailed(results []DeployResult) bool {
+	for _, r := range results {
+		if r.Status == DeployStatusFailed || r.Status == DeployStatusRolledBack {
+			return true
+		}
+	}
+	return false
+}
+
+func skipTargets(name string, targets []string, reason error) []DeployResult {
+	results := make([]DeployResult, len(targets))
+	for i, target := range targets {
+		results[i] = DeployResult{Package: name, Target: target, Status: DeployStatusSkipped, Err: reason}
+	}
+	return results
+}
+
+// RollbackError describe why remote restored previous binary
+func RollbackError(response *quicpkg.PacketPackageReplaceResponse) error {
+	if response.HealthCheck == quicpkg.HealthCheckFailed {
+		return errors.New("health check failed, remote rolled back to previous binary\n" +
+			strings.TrimSpace(response.HealthCheckMessage.Data))
+	}
+	return errors.New("after action failed, remote rolled back to previous binary\n" +
+		strings.TrimSpace(response.AfterStdout.Data))
+}
+
+func DeployTarget(name string, pkg *GoBuilderPackage, target, binaryPath, version, gitHash string) (result DeployResult) {
+	start := time.Now()
+	result = DeployResult{Package: name, Target: target, Status: DeployStatusFailed}
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	remote, err := DialDeploy(target)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer remote.CloseWithError(0, "")
+
+	if pkg.SkipUnchanged {
+		status, _, _, err := RemoteStatus(remote, name, binaryPath)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		if status == StatusInSync {
+			log.Debug("remote up to date", target, "-", name)
+			result.Status = DeployStatusSkipped
+			return result
+		}
+	}
+
+	response, err := UploadPackage(remote, name, binaryPath, version, gitHash)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	log.Debug(name, "-", target, "-", "bstdout", response.BeforeStdout.Data, "astdout", response.AfterStdout.Data)
+
+	if response.HealthCheck != quicpkg.HealthCheckNone {
+		log.Debug(name, "-", target, "-", "health check", response.HealthCheckMessage.Data)
+	}
+
+	if response.Rollback {
+		log.Debug(name, "-", target, "-", "rstdout", response.RollbackStdout.Data)
+		result.Status = DeployStatusRolledBack
+		result.Err = RollbackError(response)
+		return result
+	}
+
+	result.Status = DeployStatusDeployed
+	return result
+}
+
+func DeployTargets(name string, pkg *GoBuilderPackage, targets []string, binaryPath, version, gitHash string) []DeployResult {
+	limit := BuildConfig.DeployParallel
+	if limit <= 0 {
+		limit = 4
+	}
+
+	results := make([]DeployResult, len(targets))
+	semaphore := make(chan struct{}, limit)
+	wg := sync.WaitGroup{}
+	for i, target := range targets {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, target string) {
+			defer wg.Done()
+			results[i] = DeployTarget(name, pkg, target, binaryPath, version, gitHash)
+			<-semaphore
+		}(i, target)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// verifyCanary make sure canary host still running uploaded binary after wait
+func verifyCanary(name string, results []DeployResult, signature []byte) {
+	for i := range results {
+		if results[i].Status != DeployStatusDeployed {
+			continue
+		}
+		remote, err := DialDeploy(results[i].Target)
+		if err != nil {
+			results[i].Status, results[i].Err = DeployStatusFailed, err
+			continue
+		}
+		info, err := RemotePackageInfo(remote, name)
+		_ = remote.CloseWithError(0, "")
+		if err != nil {
+			results[i].Status, results[i].Err = DeployStatusFailed, err
+			continue
+		}
+		if !bytes.Equal(info.Signature.Data, signature) {
+			results[i].Status = DeployStatusRolledBack
+			results[i].Err = errors.New("canary binary replaced during wait")
+		}
+	}
+}
+
+// DeployPackage upload binary to every target, canary hosts first when configured
+func DeployPackage(name string, pkg *GoBuilderPackage, binaryPath, version, gitHash string) ([]DeployResult, error) {
+	targets := BuildConfig.ResolveTargets(pkg.Deploy)
+	if len(targets) == 0 {
+		return nil, errors.New("package `" + name + "` without `deploy`")
+	}
+
+	signature, _, err := FileSignature(binaryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []DeployResult
+
+	if pkg.Canary != nil && pkg.Canary.Hosts > 0 && pkg.Canary.Hosts < len(targets) {
+		canary := targets[:pkg.Canary.Hosts]
+		targets = targets[pkg.Canary.Hosts:]
+
+		log.Log("canary deploy", strings.Join(canary, ","), "-", name)
+
+		canaryResults := DeployTargets(name, pkg, canary, binaryPath, version, gitHash)
+		if !deployFailed(canaryResults) && pkg.Canary.Wait > 0 {
+			log.Log("canary wait", pkg.Canary.Wait.String(), "-", name)
+			time.Sleep(pkg.Canary.Wait)
+			verifyCanary(name, canaryResults, signature)
+		}
+
+		results = append(results, canaryResults...)
+		if deployFailed(canaryResults) {
+			results = append(results, skipTargets(name, targets, errors.New("canary failed"))...)
+			return results, errors.New("canary deploy failed, " +
+				strconv.Itoa(len(targets)) + " hosts skipped")
+		}
+	}
+
+	results = append(results, DeployTargets(name, pkg, targets, binaryPath, version, gitHash)...)
+
+	failed := 0
+	for _, r := range results {
+		if r.Status == DeployStatusFailed || r.Status == DeployStatusRolledBack {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, errors.New("deploy failed on " + strconv.Itoa(failed) + "/" +
+			strconv.Itoa(len(results)) + " hosts")
+	}
+
+	return results, nil
+}
@@ -100,17 +100,50 @@ func ReadDockerLogs(r io.ReadCloser) (int32, []byte, error) {
 	return t, buf, nil
 }
 
-func DockerBuild(name string, pkg *GoBuilderPackage) error {
-	gitBranch, gitShortHash := GitInfo(pkg.Package)
+// MainModule module of working directory
+func MainModule() (*GoModule, error) {
+	listCommand := NewGoCommand("list", "-m", "-json")
+	if err := listCommand.Start(); err != nil {
+		return nil, err
+	}
+	if err := listCommand.Wait(); err != nil {
+		return nil, err
+	}
 
+	var mod GoModule
+	if err := listCommand.JSONStdout(&mod); err != nil {
+		return nil, err
+	}
+	return &mod, nil
+}
+
+// DockerProjectDir mount path of module in container
+func DockerProjectDir(pkg *GoBuilderPackage, mod *GoModule) string {
+	if pkg.Reproducible {
+		// mount path same on every machine
+		return ReproducibleProjectDir
+	}
+	return "/go/" + filepath.Base(mod.Dir)
+}
+
+func DockerBuild(name string, pkg *GoBuilderPackage, info *BuildInfo) error {
+	_, err := DockerRun(name, pkg, append([]string{"go", "build"}, GoBuildArgs(info, BuildConfig.Version, name, pkg)...),
+		"GIT_BRANCH="+info.GitBranch,
+		"GIT_HASH="+info.GitHash,
+	)
+	return err
+}
+
+// DockerRun run command in golang container of package target with module mounted, return output
+func DockerRun(name string, pkg *GoBuilderPackage, command []string, env ...string) ([]byte, error) {
 	// use moby api interface
 	dockerApi, err := client.NewClientWithOpts()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := client.FromEnv(dockerApi); err != nil {
-		return err
+		return nil, err
 	}
 
 	goOs := pkg.BuildOS
@@ -130,7 +163,7 @@ func DockerBuild(name string, pkg *GoBuilderPackage) error {
 
 	imageId, err := getImage(dockerApi, goOs, goArch, goVersion)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if imageId == "" {
@@ -138,7 +171,7 @@ func DockerBuild(name string, pkg *GoBuilderPackage) error {
 			Platform: pkg.BuildOS + "/" + pkg.BuildArch,
 		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 		defer pullResponse.Close()
 
@@ -167,7 +200,7 @@ func DockerBuild(name string, pkg *GoBuilderPackage) error {
 				if err == io.EOF {
 					break
 				}
-				return err
+				return nil, err
 			}
 
 			if event.Status == "Pulling fs layer" {
@@ -206,7 +239,7 @@ func DockerBuild(name string, pkg *GoBuilderPackage) error {
 				percent := (allCurrent / allTotal) * 100.0
 
 				if err := commonProgressBar.Set(int(math.Round(percent))); err != nil {
-					return err
+					return nil, err
 				}
 			}
 
@@ -238,7 +271,7 @@ func DockerBuild(name string, pkg *GoBuilderPackage) error {
 				percent := (allCurrent / allTotal) * 100.0
 
 				if err := commonProgressBar.Set(int(math.Round(percent))); err != nil {
-					return err
+					return nil, err
 				}
 			}
 
@@ -253,53 +286,39 @@ func DockerBuild(name string, pkg *GoBuilderPackage) error {
 		}
 
 		if err := commonProgressBar.Close(); err != nil {
-			return err
+			return nil, err
 		}
 
 		imageId, err = getImage(dockerApi, goOs, goArch, goVersion)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if imageId == "" {
-		return fmt.Errorf("please manual download image `docker pull --platform %s/%s golang:go%s`",
+		return nil, fmt.Errorf("please manual download image `docker pull --platform %s/%s golang:go%s`",
 			goOs, goArch, goVersion)
 	}
 
-	listCommand := NewGoCommand("list", "-m", "-json")
-	if err := listCommand.Start(); err != nil {
-		return err
-	}
-	if err := listCommand.Wait(); err != nil {
-		return err
-	}
-
-	var mod GoModule
-	if err := listCommand.JSONStdout(&mod); err != nil {
-		return err
+	mod, err := MainModule()
+	if err != nil {
+		return nil, err
 	}
 
 	labels := make(map[string]string)
 	labels["gobuilder"] = runtime.Version()
 
-	projectDir := "/go/" + filepath.Base(mod.Dir)
+	projectDir := DockerProjectDir(pkg, mod)
 
 	containerConfig := &container.Config{
 		Hostname:   "gobuilder",
 		User:       "root",
 		Image:      imageId,
 		WorkingDir: projectDir,
-		Entrypoint: strslice.StrSlice{"go"},
-		Cmd: append(strslice.StrSlice{"build"},
-			GoBuildArgs(gitBranch, gitShortHash, BuildConfig.Version, name, pkg)...),
-		Labels: labels,
-		Env: []string{
-			"GIT_BRANCH=" + gitBranch,
-			"GIT_HASH=" + gitShortHash,
-			"GOOS=" + goOs,
-			"GOARCH=" + goArch,
-		},
+		Entrypoint: strslice.StrSlice{command[0]},
+		Cmd:        command[1:],
+		Labels:     labels,
+		Env:        append([]string{"GOOS=" + goOs, "GOARCH=" + goArch}, env...),
 	}
 	hostConfig := &container.HostConfig{
 		Mounts: []mount.Mount{
@@ -323,12 +342,12 @@ func DockerBuild(name string, pkg *GoBuilderPackage) error {
 		platform,
 		fmt.Sprintf("gobuilder-%s-%s-%s", goOs, goArch, name))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if err := dockerApi.ContainerStart(context.Background(), resp.ID,
 		types.ContainerStartOptions{}); err != nil {
-		return err
+		return nil, err
 	}
 
 	var exitCode int
@@ -336,7 +355,7 @@ func DockerBuild(name string, pkg *GoBuilderPackage) error {
 	for {
 		c, err := dockerApi.ContainerInspect(context.Background(), resp.ID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if !c.State.Running {
@@ -353,31 +372,31 @@ func DockerBuild(name string, pkg *GoBuilderPackage) error {
 		})
 	}()
 
-	if exitCode != 0 {
-		logs, err := dockerApi.ContainerLogs(context.Background(), resp.ID, types.ContainerLogsOptions{
-			ShowStdout: true,
-			ShowStderr: true,
-		})
-		if err != nil {
-			return err
-		}
-		defer logs.Close()
+	logs, err := dockerApi.ContainerLogs(context.Background(), resp.ID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer logs.Close()
 
-		str := bytes.NewBufferString("")
+	str := bytes.NewBufferString("")
 
-		for {
-			_, logs, err := ReadDockerLogs(logs)
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				return err
+	for {
+		_, logs, err := ReadDockerLogs(logs)
+		if err != nil {
+			if err == io.EOF {
+				break
 			}
-			str.Write(logs)
+			return nil, err
 		}
+		str.Write(logs)
+	}
 
-		return errors.New(strings.TrimSpace(str.String()))
+	if exitCode != 0 {
+		return str.Bytes(), errors.New(strings.TrimSpace(str.String()))
 	}
 
-	return nil
+	return str.Bytes(), nil
 }
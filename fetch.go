@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"gobuilder/log"
+	"gobuilder/quicpkg"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func FetchPackage(address, name, output string) ([]byte, error) {
+	remote, err := DialDeploy(address)
+	if err != nil {
+		return nil, err
+	}
+	defer remote.CloseWithError(0, "")
+
+	request, err := quicpkg.NewPackageName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := OpenRequest(remote, quicpkg.OperationPackageGet)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := request.WriteWith(stream, quicpkg.OperationPackageGet); err != nil {
+		return nil, err
+	}
+
+	if _, err := quicpkg.ReadOp(stream); err != nil {
+		return nil, err
+	}
+
+	var header quicpkg.PacketPackageHeader
+	if err := header.Read(stream); err != nil {
+		return nil, err
+	}
+
+	if dir := filepath.Dir(output); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	o, err := os.CreateTemp(filepath.Dir(output), "."+filepath.Base(output)+".*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(o.Name())
+
+	hashFunc := sha256.New()
+	n, err := quicpkg.ReadChunks(stream, io.MultiWriter(o, hashFunc), header.Size)
+	if err == nil && n != header.Size {
+		err = errors.New("data corrupt")
+	}
+	if err == nil && !bytes.Equal(hashFunc.Sum(nil), header.Signature.Data) {
+		err = errors.New("signature mismatch")
+	}
+	if err == nil {
+		err = o.Chmod(0755)
+	}
+	if closeErr := o.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return header.Signature.Data, os.Rename(o.Name(), output)
+}
+
+func FetchHandle(args []string) error {
+	fs := NewFlagSet("fetch")
+	output := fs.String("o", "", "output path, default <dest>/<pkg>.remote")
+	target := fs.String("t", "", "deploy target address, default first target")
+
+	names, err := ParseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(names) != 1 {
+		return errors.New("usage: gobuilder fetch <pkg> [-o path] [-t target]")
+	}
+
+	t, err := LookupTask(names[0])
+	if err != nil {
+		return err
+	}
+	name, pkg := t.Output, t.Package
+	if *target == "" {
+		targets := BuildConfig.ResolveTargets(pkg.Deploy)
+		if len(targets) == 0 {
+			return errors.New("package `" + name + "` without `deploy`")
+		}
+		*target = targets[0]
+	}
+
+	if *output == "" {
+		*output = filepath.Join(pkg.Dest, name+".remote")
+	}
+
+	signature, err := FetchPackage(*target, name, *output)
+	if err != nil {
+		return err
+	}
+
+	log.Ok("fetch completed", *output, shortHash(signature), "-", name)
+
+	return nil
+}
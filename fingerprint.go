@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type listModule struct {
+	Path    string
+	Version string
+	GoMod   string
+	Main    bool
+}
+
+type listPackage struct {
+	ImportPath string
+	Dir        string
+	Standard   bool
+	Module     *listModule
+	GoFiles    []string
+	CgoFiles   []string
+	CFiles     []string
+	CXXFiles   []string
+	HFiles     []string
+	SFiles     []string
+	SysoFiles  []string
+	EmbedFiles []string
+}
+
+func (p *listPackage) files() []string {
+	var files []string
+	for _, group := range [][]string{p.GoFiles, p.CgoFiles, p.CFiles, p.CXXFiles,
+		p.HFiles, p.SFiles, p.SysoFiles, p.EmbedFiles} {
+		files = append(files, group...)
+	}
+	return files
+}
+
+func hashFile(h io.Writer, path string) error {
+	o, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer o.Close()
+
+	fh := sha256.New()
+	if _, err := io.Copy(fh, o); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(h, "file %s %x\n", path, fh.Sum(nil))
+	return err
+}
+
+var hostGoVersion struct {
+	once    sync.Once
+	version string
+	err     error
+}
+
+// HostGoVersion version of `go` on PATH which build host packages, not the go gobuilder built with
+func HostGoVersion() (string, error) {
+	hostGoVersion.once.Do(func() {
+		cmd := NewGoCommand("env", "GOVERSION")
+		if err := cmd.Start(); err != nil {
+			hostGoVersion.err = err
+			return
+		}
+		if err := cmd.Wait(); err != nil {
+			hostGoVersion.err = errors.New("read host go version failed " + err.Error())
+			return
+		}
+		hostGoVersion.version = strings.TrimSpace(string(cmd.Stdout()))
+	})
+	return hostGoVersion.version, hostGoVersion.err
+}
+
+// toolchainVersion go version build the package, docker image version or host go
+func toolchainVersion(pkg *GoBuilderPackage) (string, error) {
+	if pkg.BuildMode == "docker" {
+		return BuildConfig.Version, nil
+	}
+	return HostGoVersion()
+}
+
+// listTagArgs `-tags` of build flags for `go list`, value in same or next argument
+func listTagArgs(flags []string) []string {
+	var args []string
+	for i := 0; i < len(flags); i++ {
+		name := strings.TrimPrefix(flags[i], "-")
+		switch {
+		case name == "-tags" || name == "tags":
+			if i+1 < len(flags) {
+				args = append(args, flags[i], flags[i+1])
+				i++
+			}
+		case strings.HasPrefix(name, "-tags=") || strings.HasPrefix(name, "tags="):
+			args = append(args, flags[i])
+		}
+	}
+	return args
+}
+
+// Fingerprint hash of every input of task, sources of `go list -deps`, go.sum, flags, target and toolchain
+func Fingerprint(t Task) (string, error) {
+	pkg := t.Package
+	goOS, goArch := pkg.Target()
+
+	toolchain, err := toolchainVersion(pkg)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	_, _ = fmt.Fprintln(h, "toolchain", toolchain)
+	_, _ = fmt.Fprintln(h, "mode", pkg.BuildMode)
+	_, _ = fmt.Fprintln(h, "target", goOS, goArch)
+	_, _ = fmt.Fprintln(h, "output", t.Output)
+	_, _ = fmt.Fprintln(h, "verbose", pkg.VerbosePackage)
+	_, _ = fmt.Fprintln(h, "reproducible", strconv.FormatBool(pkg.Reproducible))
+	_, _ = fmt.Fprintln(h, "flags", strings.Join(pkg.BuildFlag, " "))
+
+	if p := pkg.Packaging; p != nil {
+		_, _ = fmt.Fprintln(h, "packaging", strings.Join(p.Formats, " "), p.Name)
+		for _, pattern := range p.Files {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return "", err
+			}
+			for _, m := range matches {
+				if err := hashFile(h, m); err != nil {
+					return "", err
+				}
+			}
+		}
+	}
+
+	cmd := NewGoCommand("list", "-deps", "-json")
+	cmd.SetEnv("GOOS", goOS)
+	cmd.SetEnv("GOARCH", goArch)
+	cmd.AppendArgs(listTagArgs(pkg.BuildFlag)...)
+	cmd.AppendArgs(pkg.Package)
+
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	if err := cmd.Wait(); err != nil {
+		return "", err
+	}
+
+	goMods := make(map[string]bool)
+	decoder := json.NewDecoder(bytes.NewReader(cmd.Stdout()))
+	for decoder.More() {
+		var p listPackage
+		if err := decoder.Decode(&p); err != nil {
+			return "", err
+		}
+
+		switch {
+		case p.Standard:
+			// covered by toolchain version
+			_, _ = fmt.Fprintln(h, "std", p.ImportPath)
+		case p.Module != nil && !p.Module.Main:
+			// covered by module version and go.sum
+			_, _ = fmt.Fprintln(h, "module", p.ImportPath, p.Module.Path, p.Module.Version)
+		default:
+			_, _ = fmt.Fprintln(h, "package", p.ImportPath)
+			for _, file := range p.files() {
+				if err := hashFile(h, filepath.Join(p.Dir, file)); err != nil {
+					return "", err
+				}
+			}
+			if p.Module != nil && p.Module.GoMod != "" {
+				goMods[p.Module.GoMod] = true
+			}
+		}
+	}
+
+	for goMod := range goMods {
+		if err := hashFile(h, goMod); err != nil {
+			return "", err
+		}
+		goSum := filepath.Join(filepath.Dir(goMod), "go.sum")
+		if err := hashFile(h, goSum); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// VersionFingerprint fingerprint of inputs with version stamped into binary
+func VersionFingerprint(inputs string, version Version) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintln(h, "inputs", inputs)
+	_, _ = fmt.Fprintln(h, "version", version.String())
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// BuildCache fingerprint of last successful build of each output
+type BuildCache struct {
+	path       string
+	force      bool
+	lock       sync.Mutex
+	Entries    map[string]string `json:"entries"`
+	Undeployed map[string]bool   `json:"undeployed,omitempty"` // built without deploy, like --no-deploy
+}
+
+func LoadBuildCache(path string, force bool) (*BuildCache, error) {
+	cache := &BuildCache{path: path, force: force, Entries: make(map[string]string), Undeployed: make(map[string]bool)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, err
+	}
+	if cache.Entries == nil {
+		cache.Entries = make(map[string]string)
+	}
+	if cache.Undeployed == nil {
+		cache.Undeployed = make(map[string]bool)
+	}
+
+	return cache, nil
+}
+
+// Unchanged fingerprint same as last build and artifact still exists
+func (c *BuildCache) Unchanged(t Task, fingerprint string) bool {
+	if c.force {
+		return false
+	}
+
+	c.lock.Lock()
+	last := c.Entries[t.Output]
+	c.lock.Unlock()
+
+	if last != fingerprint {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(t.Package.Dest, t.Output))
+	return err == nil
+}
+
+// Undeployed artifact of last build never deployed
+func (c *BuildCache) IsUndeployed(t Task) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.Undeployed[t.Output]
+}
+
+func (c *BuildCache) Update(results []TaskResult) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, r := range results {
+		if r.Fingerprint == "" {
+			continue
+		}
+		switch r.Status {
+		case TaskStatusBuilt:
+			c.Entries[r.Output] = r.Fingerprint
+			c.Undeployed[r.Output] = true
+		case TaskStatusDeployed:
+			c.Entries[r.Output] = r.Fingerprint
+			delete(c.Undeployed, r.Output)
+		}
+	}
+}
+
+func (c *BuildCache) Save() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp := c.path + ".tmp"
+	if err := os.WriteFile(temp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, c.path)
+}
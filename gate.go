@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"gobuilder/log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GateTest = "test"
+	GateVet  = "vet"
+	GateLint = "lint"
+)
+
+// PreBuild quality gates run before build in build mode of package, any failure abort build and deploy
+type PreBuild struct {
+	Test *TestGate `yaml:"test,omitempty"`
+	Vet  []string  `yaml:"vet,omitempty"`  // packages to vet like ./...
+	Lint []string  `yaml:"lint,omitempty"` // command with args like staticcheck ./...
+}
+
+type TestGate struct {
+	Packages []string `yaml:"packages,omitempty"` // default ./...
+	Race     bool     `yaml:"race,omitempty"`
+	Cover    float64  `yaml:"cover,omitempty"` // minimum total statement coverage percent
+	Flags    []string `yaml:"flags,omitempty"` // extra go test flags like -short
+}
+
+type GateResult struct {
+	Name     string
+	Command  []string
+	Output   string
+	Coverage float64 // percent, -1 without coverage threshold
+	Duration time.Duration
+	Err      error
+}
+
+type gate struct {
+	name    string
+	command []string
+}
+
+// gates commands of pre build in run order, test write coverage profile when threshold set
+func (p *PreBuild) gates(profile string) []gate {
+	var gates []gate
+	if len(p.Vet) > 0 {
+		gates = append(gates, gate{name: GateVet, command: append([]string{"go", "vet"}, p.Vet...)})
+	}
+	if len(p.Lint) > 0 {
+		gates = append(gates, gate{name: GateLint, command: p.Lint})
+	}
+	if p.Test != nil {
+		command := []string{"go", "test"}
+		if p.Test.Race {
+			command = append(command, "-race")
+		}
+		if p.Test.Cover > 0 {
+			command = append(command, "-coverprofile="+profile)
+		}
+		command = append(command, p.Test.Flags...)
+		if len(p.Test.Packages) == 0 {
+			command = append(command, "./...")
+		} else {
+			command = append(command, p.Test.Packages...)
+		}
+		gates = append(gates, gate{name: GateTest, command: command})
+	}
+	return gates
+}
+
+// coverProfile total statement coverage percent of `go test -coverprofile`
+func coverProfile(path string) (float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// same block listed by several packages counted once
+	covered := make(map[string]bool)
+	statements := make(map[string]int)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "mode:") || line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return 0, errors.New("coverage profile line invalid `" + line + "`")
+		}
+		count, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, err
+		}
+		hits, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, err
+		}
+		statements[fields[0]] = count
+		if hits > 0 {
+			covered[fields[0]] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	var total, hit int
+	for block, count := range statements {
+		total += count
+		if covered[block] {
+			hit += count
+		}
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	return float64(hit) * 100 / float64(total), nil
+}
+
+func runGate(t Task, g gate) ([]byte, error) {
+	if t.Package.BuildMode == "docker" {
+		return DockerRun(t.Output+"-"+g.name, t.Package, g.command)
+	}
+
+	// host gates run for host platform, test binary of other target can not execute
+	cmd := NewCommand(g.command[0], g.command[1:]...)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	err := cmd.Wait()
+	return append(cmd.Stdout(), cmd.Stderr()...), err
+}
+
+// RunGates run pre build gates of task in order, stop at first failed gate
+func RunGates(t Task) ([]GateResult, error) {
+	pre := t.Package.PreBuild
+
+	var profile string
+	if pre.Test != nil && pre.Test.Cover > 0 {
+		// relative to module root, visible in docker mount
+		f, err := os.CreateTemp(".", ".gobuilder-cover-*.out")
+		if err != nil {
+			return nil, err
+		}
+		profile = f.Name()
+		_ = f.Close()
+		defer os.Remove(profile)
+	}
+
+	var results []GateResult
+	for _, g := range pre.gates(profile) {
+		start := time.Now()
+		log.Log("pre-build", g.name, "-", t.Output)
+
+		output, err := runGate(t, g)
+		r := GateResult{Name: g.name, Command: g.command, Output: string(output), Coverage: -1, Err: err}
+
+		if err == nil && g.name == GateTest && pre.Test.Cover > 0 {
+			if r.Coverage, err = coverProfile(profile); err != nil {
+				r.Err = errors.New("read coverage failed " + err.Error())
+			} else if r.Coverage < pre.Test.Cover {
+				r.Err = errors.New("coverage " + strconv.FormatFloat(r.Coverage, 'f', 1, 64) +
+					"% below " + strconv.FormatFloat(pre.Test.Cover, 'f', 1, 64) + "%")
+			}
+		}
+		r.Duration = time.Since(start)
+		results = append(results, r)
+
+		if r.Err != nil {
+			return results, errors.New("pre-build `" + g.name + "` failed " + r.Err.Error())
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCoverProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    float64
+		err     string
+	}{
+		{"empty", "mode: set\n", 0, ""},
+		{"all covered", "mode: set\na.go:1.1,3.2 2 1\na.go:4.1,5.2 3 1\n", 100, ""},
+		{"partial", "mode: count\na.go:1.1,3.2 1 4\na.go:4.1,5.2 3 0\n", 25, ""},
+		{"block of several packages counted once", "mode: atomic\na.go:1.1,3.2 2 0\nb.go:1.1,2.2 2 0\na.go:1.1,3.2 2 5\n", 50, ""},
+		{"line invalid", "mode: set\na.go:1.1,3.2 2\n", 0, "coverage profile line invalid"},
+		{"count invalid", "mode: set\na.go:1.1,3.2 x 1\n", 0, "invalid syntax"},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "cover.out")
+		writeFiles(t, filepath.Dir(path), map[string]string{"cover.out": test.profile})
+
+		got, err := coverProfile(path)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error %v want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: coverage %.2f want %.2f", test.name, got, test.want)
+		}
+	}
+}
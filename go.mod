@@ -9,6 +9,7 @@ require (
 	github.com/opencontainers/image-spec v1.0.2
 	github.com/panjf2000/ants/v2 v2.5.0
 	github.com/schollz/progressbar/v3 v3.8.6
+	golang.org/x/sys v0.0.0-20220627191245-f75cf1eec38b
 	gopkg.in/yaml.v3 v3.0.1
 )
 
@@ -38,7 +39,6 @@ require (
 	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
 	golang.org/x/mod v0.4.2 // indirect
 	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2 // indirect
-	golang.org/x/sys v0.0.0-20220627191245-f75cf1eec38b // indirect
 	golang.org/x/term v0.0.0-20220526004731-065cf7ba2467 // indirect
 	golang.org/x/time v0.0.0-20220609170525-579cf78fd858 // indirect
 	golang.org/x/tools v0.1.1 // indirect
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"gobuilder/log"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	HookBeforeBuild = "before-build"
+	HookAfterBuild  = "after-build"
+	HookAfterDeploy = "after-deploy"
+)
+
+// HookEnv values hook receive as PACKAGE_* environment
+type HookEnv struct {
+	Version  string
+	Artifact string
+	Sha256   string
+	Deploy   []string // deployed targets, after-deploy only
+}
+
+// hookLocks targets of one package share sources, their hooks never run together
+var hookLocks sync.Map
+
+// RunHooks run hook commands of stage in order, first failed hook abort the rest
+func RunHooks(stage string, hooks []string, t Task, env HookEnv) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	lock, _ := hookLocks.LoadOrStore(t.Name, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if env.Artifact == "" {
+		env.Artifact = filepath.Join(t.Package.Dest, t.Output)
+	}
+	goOS, goArch := t.Package.Target()
+
+	for _, hook := range hooks {
+		args := strings.Fields(hook)
+		if len(args) == 0 {
+			continue
+		}
+
+		cmd := NewCommand(args[0], args[1:]...).
+			SetEnv("PACKAGE_NAME", t.Name).
+			SetEnv("PACKAGE_OUTPUT", t.Output).
+			SetEnv("PACKAGE_VERSION", env.Version).
+			SetEnv("PACKAGE_PATH", env.Artifact).
+			SetEnv("PACKAGE_HASH", env.Sha256).
+			SetEnv("PACKAGE_TARGET", goOS+"/"+goArch).
+			SetEnv("PACKAGE_OS", goOS).
+			SetEnv("PACKAGE_ARCH", goArch).
+			SetEnv("PACKAGE_DEPLOY", strings.Join(env.Deploy, ","))
+
+		log.Debug(stage, "hook", hook, "-", t.Output)
+
+		if err := cmd.Start(); err != nil {
+			return errors.New(stage + " hook `" + hook + "` failed " + err.Error())
+		}
+		if err := cmd.Wait(); err != nil {
+			return errors.New(stage + " hook `" + hook + "` failed " + err.Error())
+		}
+		if output := strings.TrimSpace(string(cmd.Stdout())); output != "" {
+			log.Debug(stage, "hook output", output, "-", t.Output)
+		}
+	}
+
+	return nil
+}
@@ -37,27 +37,92 @@ func GitInfo(pkg string) (string, string) {
 	return gitBranch, gitShortHash
 }
 
-func GoBuildArgs(gitBranch, gitShortHash, goVersion, name string, pkg *GoBuilderPackage) []string {
+// GitDirty tracked sources of package modified, gobuilder config, state, cache and dest not count
+func GitDirty(pkg *GoBuilderPackage) (bool, error) {
+	args := []string{"status", "--porcelain", "--untracked-files=no", "--", ".", ":(exclude)" + CLI.ConfigPath + "*"}
+	if pkg.Dest != "" && filepath.Clean(pkg.Dest) != "." {
+		args = append(args, ":(exclude)"+pkg.Dest)
+	}
+	status, err := gitOutput(args...)
+	if err != nil {
+		return false, err
+	}
+	return status != "", nil
+}
+
+// BuildInfo values inject to `verbose-package` of binary
+type BuildInfo struct {
+	Version    string `json:"version"`
+	BuildStamp string `json:"build-stamp"`
+	GitHash    string `json:"git-hash,omitempty"`
+	GitBranch  string `json:"git-branch,omitempty"`
+	GitDirty   bool   `json:"git-dirty,omitempty"`
+}
+
+func NewBuildInfo(pkg *GoBuilderPackage) (*BuildInfo, error) {
+	gitBranch, gitShortHash := GitInfo(pkg.Package)
+
+	// reproducible build stamp from source instead of clock
+	stamp := time.Now()
+	if pkg.Reproducible {
+		var err error
+		if stamp, err = SourceDateEpoch(); err != nil {
+			return nil, err
+		}
+	}
+
+	var dirty bool
+	if gitShortHash != "" {
+		var err error
+		if dirty, err = GitDirty(pkg); err != nil {
+			log.Warn("package", pkg.Package, "resolve git status failed", err)
+		}
+	}
+
+	return &BuildInfo{
+		Version:    pkg.BuildVersion().String(),
+		BuildStamp: stamp.UTC().Format(time.RFC3339),
+		GitHash:    gitShortHash,
+		GitBranch:  gitBranch,
+		GitDirty:   dirty,
+	}, nil
+}
+
+// GitRef short hash with branch as `GitHash` variable, empty outside git
+func (i *BuildInfo) GitRef() string {
+	if i.GitHash == "" {
+		return ""
+	}
+	return i.GitHash + "/" + i.GitBranch
+}
+
+func GoBuildArgs(info *BuildInfo, goVersion, name string, pkg *GoBuilderPackage) []string {
 	var args []string
 
 	var ldflags []string
+	if pkg.Reproducible {
+		// vcs stamp flip to modified once auto upgrade rewrite config, git hash already in ldflags
+		args = append(args, "-trimpath", "-buildvcs=false")
+		ldflags = append(ldflags, "-buildid=")
+	}
 	if pkg.VerbosePackage != "" {
-		strTime := time.Now().Format(time.RFC3339)
+		buildTool := "gobuilder/" + goVersion + "/" + pkg.BuildMode
+		if !pkg.Reproducible {
+			// platform running gobuilder differ between rebuilds, keep out of reproducible binary
+			buildTool += "/" + runtime.GOOS + "/" + runtime.GOARCH
+		}
 		ldflags = append(ldflags,
 			"-w",
-			"-X", "'"+pkg.VerbosePackage+".Version="+pkg.Version.String()+"'",
-			"-X", "'"+pkg.VerbosePackage+".BuildStamp="+strTime+"'",
-			"-X", "'"+pkg.VerbosePackage+".BuildTool=gobuilder/"+
-				goVersion+
-				"/"+pkg.BuildMode+
-				"/"+runtime.GOOS+
-				"/"+runtime.GOARCH+"'",
+			"-X", "'"+pkg.VerbosePackage+".Version="+info.Version+"'",
+			"-X", "'"+pkg.VerbosePackage+".BuildStamp="+info.BuildStamp+"'",
+			"-X", "'"+pkg.VerbosePackage+".BuildTool="+buildTool+"'",
 		)
 
-		if gitShortHash != "" {
-			ldflags = append(ldflags, "-X", "'"+pkg.VerbosePackage+".GitHash="+gitShortHash+
-				"/"+gitBranch+"'")
+		if info.GitHash != "" {
+			ldflags = append(ldflags, "-X", "'"+pkg.VerbosePackage+".GitHash="+info.GitRef()+"'")
 		}
+	}
+	if len(ldflags) > 0 {
 		args = append(args, "-ldflags="+strings.Join(ldflags, " "))
 	}
 
@@ -72,10 +137,13 @@ func GoBuildArgs(gitBranch, gitShortHash, goVersion, name string, pkg *GoBuilder
 	return append(args, pkg.Package)
 }
 
-func HostBuild(name string, pkg *GoBuilderPackage) error {
-	gitBranch, gitShortHash := GitInfo(pkg.Package)
-
-	goVersion := strings.TrimPrefix(runtime.Version(), "go")
+func HostBuild(name string, pkg *GoBuilderPackage, info *BuildInfo) error {
+	// go on PATH run the build, not the go gobuilder built with
+	hostVersion, err := HostGoVersion()
+	if err != nil {
+		return err
+	}
+	goVersion := strings.TrimPrefix(hostVersion, "go")
 	if goVersion != BuildConfig.Version {
 		log.Warn(fmt.Sprintf("host go version not match config version %s<->%s", goVersion, BuildConfig.Version))
 		BuildConfig.Version = goVersion
@@ -90,7 +158,7 @@ func HostBuild(name string, pkg *GoBuilderPackage) error {
 		cmd.SetEnv("GOARCH", pkg.BuildArch)
 	}
 
-	args := GoBuildArgs(gitBranch, gitShortHash, goVersion, name, pkg)
+	args := GoBuildArgs(info, goVersion, name, pkg)
 	cmd.AppendArgs("build").
 		AppendArgs(args...)
 
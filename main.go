@@ -1,114 +1,131 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"gobuilder/log"
-	"gopkg.in/yaml.v3"
 	"os"
-	"sync"
+	"sort"
 )
 
 var BuildConfig GoBuilderConfig
 
 type Task struct {
-	Name    string
+	Name    string // package name in config
+	Output  string // binary and remote package name
 	Package *GoBuilderPackage
 }
 
-func main() {
-	// read config file suffix
-	goBuilderEnv := os.Getenv("GOBUILDER_ENV")
+var commands = map[string]func(args []string) error{
+	"build":    BuildHandle,
+	"deploy":   DeployHandle,
+	"status":   StatusHandle,
+	"diff":     StatusHandle,
+	"plan":     PlanHandle,
+	"list":     ListHandle,
+	"clean":    CleanHandle,
+	"version":  VersionHandle,
+	"init":     InitHandle,
+	"rollback": RollbackHandle,
+	"verify":   VerifyHandle,
+	"fetch":    FetchHandle,
+}
 
-	goBuilderConfigPath := ".gobuilder"
-	if goBuilderEnv != "" {
-		goBuilderConfigPath += "." + goBuilderEnv
+const usage = `usage: gobuilder [flags] <command> [flags] [packages]
+
+commands:
+  build      build packages, deploy packages with ` + "`deploy`" + ` (default)
+  deploy     deploy last built or archived release without rebuild
+  plan       print build command, version bump and deploy action without side effect
+  status     compare local binaries with deploy targets, alias diff
+  list       list configured packages
+  clean      remove built binaries and archives
+  version    print gobuilder version
+  init       write starter config for main packages of module
+  rollback   switch deploy targets to previous or given version
+  verify     rebuild reproducible package and compare with artifact
+  fetch      download deployed binary
+
+flags:
+`
+
+// commandSuggestion closest command of mistyped name not used by any package
+func commandSuggestion(name string) string {
+	if len(name) == 0 || name[0] == '-' {
+		return ""
 	}
-
-	o, err := os.Open(goBuilderConfigPath)
-	if err != nil {
-		log.Error("`" + goBuilderConfigPath + "` invalid")
-		return
+	// read package names only, BuildConfig load after command flags parsed
+	// without config every name is a candidate
+	var config GoBuilderConfig
+	_ = LoadConfig(CLI.ConfigPath, CLI.Env, &config)
+	for _, candidate := range configPackageNames(&config) {
+		if candidate == name {
+			return ""
+		}
 	}
 
-	err = yaml.NewDecoder(o).Decode(&BuildConfig)
-	if err != nil {
-		log.Error("yaml decode file failed", err)
-		return
+	var names []string
+	for command := range commands {
+		names = append(names, command)
 	}
-
-	if err := o.Close(); err != nil {
-		log.Error("close file failed", err)
-		return
+	sort.Strings(names)
+	if best, ok := closest(name, names); ok {
+		return best
 	}
+	return ""
+}
 
-	log.DebugEnabled = BuildConfig.Verbose
-
-	commands := os.Args[1:]
+func main() {
+	os.Exit(run())
+}
 
-	parallel := 1
-	if BuildConfig.Parallel > 1 {
-		parallel = BuildConfig.Parallel
+func run() int {
+	fs := flag.NewFlagSet("gobuilder", flag.ContinueOnError)
+	CLI.Register(fs)
+	shared := make(map[string]bool)
+	fs.VisitAll(func(f *flag.Flag) {
+		shared[f.Name] = true
+	})
+	// build flags before the command, keep `gobuilder --force` working
+	var leading BuildFlags
+	leading.Register(fs)
+	fs.Usage = func() {
+		_, _ = fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
 	}
-
-	wg := sync.WaitGroup{}
-	parallelWaitGroup := sync.WaitGroup{}
-	parallelWaitGroup.Add(parallel)
-
-	taskQueue := make(chan Task, parallel)
-
-	for i := 0; i < parallel; i++ {
-		go func() {
-			for {
-				t, ok := <-taskQueue
-				if !ok {
-					parallelWaitGroup.Done()
-					return
-				}
-				if err := ProcessTask(&wg, t); err != nil {
-					log.Error("build package `"+t.Name+"` failed", err)
-				}
-			}
-		}()
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
 	}
 
-	if len(commands) > 0 {
-		for _, n := range commands {
-			for pName, pkg := range BuildConfig.Packages {
-				if pName == n {
-					taskQueue <- Task{Name: pName, Package: pkg}
-					wg.Add(1)
-					break
-				}
-			}
-		}
-	} else {
-		// start build all command
-		for k, v := range BuildConfig.Packages {
-			taskQueue <- Task{Name: k, Package: v}
-			wg.Add(1)
+	// bare package names build, keep `gobuilder pkg` working
+	command, args := "build", fs.Args()
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			command, args = args[0], args[1:]
+		} else if suggestion := commandSuggestion(args[0]); suggestion != "" {
+			log.Error("unknown command `" + args[0] + "`, did you mean `" + suggestion + "`?")
+			return 2
 		}
 	}
 
-	wg.Wait()
-	close(taskQueue)
-	parallelWaitGroup.Wait()
-
-	// clean up
-
-	if BuildConfig.AutoUpgrade {
-		configBytes, err := yaml.Marshal(BuildConfig)
-		if err != nil {
-			log.Error("marshal config failed", err)
-			return
+	// hand build flags to the command, any other command reject them
+	var forwarded []string
+	fs.Visit(func(f *flag.Flag) {
+		if !shared[f.Name] {
+			forwarded = append(forwarded, "-"+f.Name+"="+f.Value.String())
 		}
-		o, err := os.Create(goBuilderConfigPath)
-		if err != nil {
-			log.Error("create config failed", err)
-			return
-		}
-		defer o.Close()
-		if _, err := o.Write(configBytes); err != nil {
-			log.Error("write config failed", err)
-			return
+	})
+	args = append(forwarded, args...)
+
+	if err := commands[command](args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
 		}
+		log.Error(command+" failed", err)
+		return 1
 	}
+	return 0
 }
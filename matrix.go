@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+const DefaultMatrixOutput = "{name}-{os}-{arch}{ext}"
+
+// OutputName expand output template of package for target, `.exe` ext for windows
+func OutputName(template, name, goOS, goArch string) string {
+	var ext string
+	if goOS == "windows" {
+		ext = ".exe"
+	}
+	return strings.NewReplacer(
+		"{name}", name,
+		"{os}", goOS,
+		"{arch}", goArch,
+		"{ext}", ext,
+	).Replace(template)
+}
+
+// Tasks expand package into one task for each of `targets`, a single task when not set.
+// task package is a copy with BuildOS BuildArch of the target, version shared with origin
+func (p *GoBuilderPackage) Tasks(name string) ([]Task, error) {
+	if len(p.Matrix) == 0 {
+		output := name
+		if p.Output != "" {
+			goOS, goArch := p.Target()
+			output = OutputName(p.Output, name, goOS, goArch)
+		}
+		return []Task{{Name: name, Output: output, Package: p}}, nil
+	}
+
+	template := p.Output
+	if template == "" {
+		template = DefaultMatrixOutput
+	}
+
+	var tasks []Task
+	outputs := make(map[string]bool)
+	for _, target := range p.Matrix {
+		parts := strings.Split(target, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("package `" + name + "` target `" + target + "` invalid, expect os/arch")
+		}
+
+		output := OutputName(template, name, parts[0], parts[1])
+		if outputs[output] {
+			return nil, errors.New("package `" + name + "` output `" + output + "` duplicated, check `output` template")
+		}
+		outputs[output] = true
+
+		pkg := *p
+		pkg.BuildOS = parts[0]
+		pkg.BuildArch = parts[1]
+		tasks = append(tasks, Task{Name: name, Output: output, Package: &pkg})
+	}
+
+	return tasks, nil
+}
+
+// LookupTask find task by package name or matrix output name
+func LookupTask(name string) (Task, error) {
+	if pkg, ok := BuildConfig.Packages[name]; ok {
+		tasks, err := pkg.Tasks(name)
+		if err != nil {
+			return Task{}, err
+		}
+		if len(tasks) == 1 {
+			return tasks[0], nil
+		}
+		outputs := make([]string, len(tasks))
+		for i, t := range tasks {
+			outputs[i] = t.Output
+		}
+		return Task{}, errors.New("package `" + name + "` has multiple targets, use one of " + strings.Join(outputs, ", "))
+	}
+
+	var names []string
+	for n := range BuildConfig.Packages {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		tasks, err := BuildConfig.Packages[n].Tasks(n)
+		if err != nil {
+			return Task{}, err
+		}
+		for _, t := range tasks {
+			if t.Output == name {
+				return t, nil
+			}
+		}
+	}
+
+	return Task{}, NotFoundError(name)
+}
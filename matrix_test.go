@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		template, goOS, goArch, want string
+	}{
+		{DefaultMatrixOutput, "linux", "amd64", "api-linux-amd64"},
+		{DefaultMatrixOutput, "windows", "amd64", "api-windows-amd64.exe"},
+		{"{name}_{arch}", "darwin", "arm64", "api_arm64"},
+		{"bin/{os}/{name}{ext}", "windows", "386", "bin/windows/api.exe"},
+		{"fixed", "linux", "arm64", "fixed"},
+	}
+
+	for _, test := range tests {
+		if got := OutputName(test.template, "api", test.goOS, test.goArch); got != test.want {
+			t.Errorf("%s %s/%s %s want %s", test.template, test.goOS, test.goArch, got, test.want)
+		}
+	}
+}
+
+func TestTasks(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkg     GoBuilderPackage
+		outputs string
+		targets string
+		err     string
+	}{
+		{"single", GoBuilderPackage{BuildOS: "linux", BuildArch: "arm64"}, "api", "linux/arm64", ""},
+		{"single output template", GoBuilderPackage{BuildOS: "windows", BuildArch: "amd64", Output: "{name}{ext}"}, "api.exe", "windows/amd64", ""},
+		{"matrix", GoBuilderPackage{Matrix: []string{"linux/amd64", "windows/arm64"}},
+			"api-linux-amd64 api-windows-arm64.exe", "linux/amd64 windows/arm64", ""},
+		{"matrix output template", GoBuilderPackage{Matrix: []string{"linux/amd64", "darwin/arm64"}, Output: "{os}/{name}"},
+			"linux/api darwin/api", "linux/amd64 darwin/arm64", ""},
+		{"invalid target", GoBuilderPackage{Matrix: []string{"linux"}}, "", "", "target `linux` invalid"},
+		{"empty arch", GoBuilderPackage{Matrix: []string{"linux/"}}, "", "", "target `linux/` invalid"},
+		{"duplicated output", GoBuilderPackage{Matrix: []string{"linux/amd64", "linux/arm64"}, Output: "{name}-{os}"}, "", "", "output `api-linux` duplicated"},
+	}
+
+	for _, test := range tests {
+		pkg := test.pkg
+		pkg.Version = &Version{Major: 1}
+		tasks, err := pkg.Tasks("api")
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error %v want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		var outputs, targets []string
+		for _, task := range tasks {
+			goOS, goArch := task.Package.Target()
+			outputs = append(outputs, task.Output)
+			targets = append(targets, goOS+"/"+goArch)
+			if task.Name != "api" {
+				t.Errorf("%s: task name %s", test.name, task.Name)
+			}
+			if task.Package.Version != pkg.Version {
+				t.Errorf("%s: task version not shared with package", test.name)
+			}
+		}
+		if strings.Join(outputs, " ") != test.outputs || strings.Join(targets, " ") != test.targets {
+			t.Errorf("%s: outputs %v targets %v want %s %s", test.name, outputs, targets, test.outputs, test.targets)
+		}
+	}
+}
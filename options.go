@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"gobuilder/log"
+	"os"
+	"strings"
+)
+
+// Options flags shared by every command
+type Options struct {
+	ConfigPath    string
+	Env           string
+	Parallel      int
+	Verbose       bool
+	Targets       string
+	DryRun        bool
+	NoVersionBump bool
+
+	loaded bool
+}
+
+var CLI = Options{
+	ConfigPath: ".gobuilder",
+	Env:        os.Getenv("GOBUILDER_ENV"),
+}
+
+func (o *Options) Register(fs *flag.FlagSet) {
+	fs.StringVar(&o.ConfigPath, "config", o.ConfigPath, "config file `path`")
+	fs.StringVar(&o.Env, "env", o.Env, "overlay `<config>.<env>` on config, default $GOBUILDER_ENV")
+	fs.IntVar(&o.Parallel, "parallel", o.Parallel, "build how many packages in once, override config `parallel`")
+	fs.BoolVar(&o.Verbose, "verbose", o.Verbose, "debug log")
+	fs.StringVar(&o.Targets, "target", o.Targets, "build `os/arch` list like linux/amd64,linux/arm64 instead of configured")
+	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "print what would be done without side effect")
+	fs.BoolVar(&o.NoVersionBump, "no-version-bump", o.NoVersionBump, "keep version of successful packages")
+}
+
+// EnvConfigPath config path with env suffix, prefix of state and cache file
+func (o *Options) EnvConfigPath() string {
+	if o.Env != "" {
+		return o.ConfigPath + "." + o.Env
+	}
+	return o.ConfigPath
+}
+
+// NewFlagSet command flag set with shared options registered
+func NewFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("gobuilder "+name, flag.ContinueOnError)
+	CLI.Register(fs)
+	return fs
+}
+
+// ParseCommand parse command args then load config with options applied
+func ParseCommand(fs *flag.FlagSet, args []string) ([]string, error) {
+	names, err := ParseArgs(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	return names, CLI.Load()
+}
+
+// overrideTargets build package for targets instead of configured, matrix package keep output template
+func overrideTargets(pkg *GoBuilderPackage, targets []string) error {
+	if len(pkg.Matrix) > 0 || len(targets) > 1 {
+		pkg.Matrix = targets
+		return nil
+	}
+
+	parts := strings.Split(targets[0], "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return errors.New("target `" + targets[0] + "` invalid, expect os/arch")
+	}
+	pkg.BuildOS, pkg.BuildArch = parts[0], parts[1]
+	return nil
+}
+
+func (o *Options) Load() error {
+	if o.loaded {
+		return nil
+	}
+
+	if err := LoadConfig(o.ConfigPath, o.Env, &BuildConfig); err != nil {
+		return errors.New("load config `" + o.EnvConfigPath() + "` failed " + err.Error())
+	}
+
+	if o.Verbose {
+		BuildConfig.Verbose = true
+	}
+	log.DebugEnabled = BuildConfig.Verbose
+
+	if o.Parallel > 0 {
+		BuildConfig.Parallel = o.Parallel
+	}
+
+	if o.Targets != "" {
+		targets := strings.Split(o.Targets, ",")
+		for _, pkg := range BuildConfig.Packages {
+			if err := overrideTargets(pkg, targets); err != nil {
+				return err
+			}
+		}
+	}
+
+	state, err := ReadVersionState(StatePath(o.EnvConfigPath()))
+	if err != nil {
+		return errors.New("read version state failed " + err.Error())
+	}
+	ApplyVersionState(state)
+
+	o.loaded = true
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// PlanHandle print build and deploy plan of packages without building
+func PlanHandle(args []string) error {
+	names, err := ParseCommand(NewFlagSet("plan"), args)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := SelectTasks(names)
+	if err != nil {
+		return err
+	}
+
+	graph, err := NewTaskGraph(tasks)
+	if err != nil {
+		return errors.New("build graph invalid " + err.Error())
+	}
+
+	return PrintPlan(os.Stdout, graph.Tasks, true)
+}
+
+// shellJoin quote args with space or quote for display only
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " '\"") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// planVersion version built and the bump applied after success
+func planVersion(pkg *GoBuilderPackage) string {
+	version := pkg.BuildVersion()
+	next, hold := pkg.NextVersion()
+	if hold != "" {
+		return version.String() + " (" + hold + ")"
+	}
+	return version.String() + " -> " + next.String() + " on success"
+}
+
+// planDeploy deploy action of package in order
+func planDeploy(t Task, binaryPath string) []string {
+	targets := BuildConfig.ResolveTargets(t.Package.Deploy)
+	if len(targets) == 0 {
+		return []string{"none"}
+	}
+
+	var steps []string
+	if c := t.Package.Canary; c != nil && c.Hosts > 0 && c.Hosts < len(targets) {
+		steps = append(steps, "upload "+t.Output+" to canary "+strings.Join(targets[:c.Hosts], ","))
+		if c.Wait > 0 {
+			steps = append(steps, "wait "+c.Wait.String()+" then verify canary")
+		}
+		targets = targets[c.Hosts:]
+	}
+
+	upload := "upload " + t.Output + " to " + strings.Join(targets, ",")
+	if t.Package.SkipUnchanged {
+		upload += ", skip target with same sha256"
+	}
+	if BuildConfig.DeployParallel > 0 {
+		upload += ", " + strconv.Itoa(BuildConfig.DeployParallel) + " in parallel"
+	}
+	steps = append(steps, upload)
+
+	for _, hook := range t.Package.AfterDeploy {
+		steps = append(steps, HookAfterDeploy+" "+hook)
+	}
+	if t.Package.CleanAfterDeploy {
+		steps = append(steps, "remove "+binaryPath)
+	}
+	return steps
+}
+
+// PrintPlan resolve version, git info, toolchain and deploy targets of tasks then print
+// go build command of each, nothing built, written or sent
+func PrintPlan(w io.Writer, tasks []Task, deploy bool) error {
+	var mod *GoModule
+
+	for i, t := range tasks {
+		pkg := t.Package
+		info, err := NewBuildInfo(pkg)
+		if err != nil {
+			return err
+		}
+
+		goOS, goArch := pkg.Target()
+		binaryPath := filepath.Join(pkg.Dest, t.Output)
+
+		var env []string
+		var toolchain, goVersion string
+		switch pkg.BuildMode {
+		case "host":
+			goVersion = strings.TrimPrefix(runtime.Version(), "go")
+			toolchain = "host go" + goVersion
+			if goVersion != BuildConfig.Version {
+				toolchain += " (config " + BuildConfig.Version + ")"
+			}
+			if pkg.BuildOS != "" {
+				env = append(env, "GOOS="+pkg.BuildOS)
+			}
+			if pkg.BuildArch != "" {
+				env = append(env, "GOARCH="+pkg.BuildArch)
+			}
+		case "docker":
+			goVersion = BuildConfig.Version
+			image := goVersion
+			if image == "" {
+				image = "latest"
+			}
+			if mod == nil {
+				if mod, err = MainModule(); err != nil {
+					return err
+				}
+			}
+			toolchain = "docker golang:" + image + " " + goOS + "/" + goArch +
+				", pull when missing, mount " + mod.Dir + " at " + DockerProjectDir(pkg, mod)
+			env = append(env, "GOOS="+goOS, "GOARCH="+goArch)
+		default:
+			return errors.New("package `" + t.Output + "` invalid `build-mode`")
+		}
+
+		args := append([]string{"go", "build"}, GoBuildArgs(info, goVersion, t.Output, pkg)...)
+
+		if i > 0 {
+			_, _ = fmt.Fprintln(w)
+		}
+		_, _ = fmt.Fprintf(w, "%s (%s) %s/%s\n", t.Output, t.Name, goOS, goArch)
+		_, _ = fmt.Fprintln(w, "  version:  ", planVersion(pkg))
+		if ref := info.GitRef(); ref != "" {
+			_, _ = fmt.Fprintln(w, "  git:      ", ref)
+		}
+		if len(pkg.DependsOn) > 0 {
+			_, _ = fmt.Fprintln(w, "  after:    ", strings.Join(pkg.DependsOn, ","))
+		}
+		_, _ = fmt.Fprintln(w, "  toolchain:", toolchain)
+		for _, hook := range pkg.BeforeBuild {
+			_, _ = fmt.Fprintln(w, "  hook:     ", HookBeforeBuild, hook)
+		}
+		if pkg.PreBuild != nil {
+			for _, g := range pkg.PreBuild.gates(".gobuilder-cover.out") {
+				line := g.name + ": " + shellJoin(g.command)
+				if g.name == GateTest && pkg.PreBuild.Test.Cover > 0 {
+					line += fmt.Sprintf(", coverage >= %.1f%%", pkg.PreBuild.Test.Cover)
+				}
+				_, _ = fmt.Fprintln(w, "  pre-build:", line)
+			}
+		}
+		_, _ = fmt.Fprintln(w, "  command:  ", shellJoin(append(env, args...)))
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "-ldflags=") {
+				_, _ = fmt.Fprintln(w, "  ldflags:  ", strings.TrimPrefix(arg, "-ldflags="))
+			}
+		}
+		_, _ = fmt.Fprintln(w, "  output:   ", binaryPath, "+", filepath.Base(MetaPath(binaryPath)))
+		for _, hook := range pkg.AfterBuild {
+			_, _ = fmt.Fprintln(w, "  hook:     ", HookAfterBuild, hook)
+		}
+		if pkg.Packaging != nil {
+			formats, err := pkg.Packaging.formats()
+			if err != nil {
+				return err
+			}
+			base := filepath.Join(pkg.Dest, archiveBase(t, info.Version))
+			for _, format := range formats {
+				_, _ = fmt.Fprintln(w, "  archive:  ", base+"."+format)
+			}
+		}
+		if !deploy {
+			_, _ = fmt.Fprintln(w, "  deploy:    skipped, --no-deploy")
+			continue
+		}
+		for _, step := range planDeploy(t, binaryPath) {
+			_, _ = fmt.Fprintln(w, "  deploy:   ", step)
+		}
+	}
+
+	return nil
+}
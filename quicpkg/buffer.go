@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"unicode/utf8"
 )
 
 var binaryOrder = binary.BigEndian
@@ -21,8 +22,43 @@ type Data[S DataSize, T DataType] struct {
 	Data T
 }
 
+// maxSize largest data length size type S can hold
+func maxSize[S DataSize]() uint64 {
+	var zero S
+	return uint64(^zero)
+}
+
+func NewString[S DataSize](s string) (Data[S, string], error) {
+	if uint64(len(s)) > maxSize[S]() {
+		return Data[S, string]{}, errors.New("string length overflow")
+	}
+	return Data[S, string]{Size: S(len(s)), Data: s}, nil
+}
+
+// TruncateString string cut to max length of S, for output and message only informative
+func TruncateString[S DataSize](s string) Data[S, string] {
+	if max := maxSize[S](); uint64(len(s)) > max {
+		s = s[:max]
+		// drop rune split by cut
+		for len(s) > 0 && !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+	}
+	return Data[S, string]{Size: S(len(s)), Data: s}
+}
+
+func NewBytes[S DataSize](b []byte) (Data[S, []byte], error) {
+	if uint64(len(b)) > maxSize[S]() {
+		return Data[S, []byte]{}, errors.New("bytes length overflow")
+	}
+	return Data[S, []byte]{Size: S(len(b)), Data: b}, nil
+}
+
 func WriteData[S DataSize, T DataType](w io.Writer, data Data[S, T]) error {
 	dataLen := data.Size
+	if uint64(len(data.Data)) != uint64(dataLen) {
+		return errors.New("data size not match length")
+	}
 	if err := binary.Write(w, binaryOrder, dataLen); err != nil {
 		return err
 	}
@@ -0,0 +1,156 @@
+package quicpkg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+const (
+	ProtocolVersion    uint16 = 1
+	MinProtocolVersion uint16 = 1
+)
+
+var ProtocolMagic = [4]byte{'G', 'B', 'Q', 'P'}
+
+// PacketHandshake first packet of every stream, client send then server reply with OperationHandshake
+type PacketHandshake struct {
+	Version    uint16
+	RequestID  uint32
+	Operations Data[uint8, []byte] // supported operations
+}
+
+func NewHandshake(requestID uint32, ops []Operation) (PacketHandshake, error) {
+	raw := make([]byte, len(ops))
+	for i, op := range ops {
+		raw[i] = byte(op)
+	}
+	operations, err := NewBytes[uint8](raw)
+	if err != nil {
+		return PacketHandshake{}, err
+	}
+	return PacketHandshake{
+		Version:    ProtocolVersion,
+		RequestID:  requestID,
+		Operations: operations,
+	}, nil
+}
+
+func (p *PacketHandshake) Supports(op Operation) bool {
+	return bytes.IndexByte(p.Operations.Data, byte(op)) >= 0
+}
+
+func (p *PacketHandshake) Read(stream io.Reader) error {
+	if err := Read(stream, &p.Version); err != nil {
+		return err
+	}
+	if err := Read(stream, &p.RequestID); err != nil {
+		return err
+	}
+	if err := ReadData(stream, &p.Operations); err != nil {
+		return err
+	}
+	return nil
+}
+func (p *PacketHandshake) Write(stream io.Writer) error {
+	if err := Write(stream, p.Version); err != nil {
+		return err
+	}
+	if err := Write(stream, p.RequestID); err != nil {
+		return err
+	}
+	if err := WriteData(stream, p.Operations); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteWithMagic client side handshake
+func (p *PacketHandshake) WriteWithMagic(stream io.Writer) error {
+	if _, err := stream.Write(ProtocolMagic[:]); err != nil {
+		return err
+	}
+	return p.Write(stream)
+}
+
+// WriteWithOp server side handshake
+func (p *PacketHandshake) WriteWithOp(stream io.Writer) error {
+	if err := Write[byte](stream, OperationHandshake); err != nil {
+		return err
+	}
+	return p.Write(stream)
+}
+
+// ClientHandshake send client handshake, fail when server not support op
+func ClientHandshake(stream io.ReadWriter, requestID uint32, op Operation) (*PacketHandshake, error) {
+	request, err := NewHandshake(requestID, []Operation{op})
+	if err != nil {
+		return nil, err
+	}
+	if err := request.WriteWithMagic(stream); err != nil {
+		return nil, err
+	}
+
+	if _, err := ReadOp(stream); err != nil {
+		return nil, err
+	}
+
+	var response PacketHandshake
+	if err := response.Read(stream); err != nil {
+		return nil, err
+	}
+	if response.RequestID != requestID {
+		return nil, errors.New("handshake request id mismatch")
+	}
+	if !response.Supports(op) {
+		return nil, errors.New("server protocol " + strconv.FormatUint(uint64(response.Version), 10) +
+			" not support operation `" + op.String() + "`")
+	}
+
+	return &response, nil
+}
+
+// ServerHandshake read client handshake, reply negotiated version and server operations
+func ServerHandshake(stream io.ReadWriter, ops []Operation) (*PacketHandshake, error) {
+	var magic [4]byte
+	if _, err := io.ReadFull(stream, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != ProtocolMagic {
+		return nil, writeHandshakeError(stream, ErrorCodeProtocol, "invalid protocol magic")
+	}
+
+	var request PacketHandshake
+	if err := request.Read(stream); err != nil {
+		return nil, err
+	}
+	if request.Version < MinProtocolVersion {
+		return nil, writeHandshakeError(stream, ErrorCodeProtocol,
+			"protocol version "+strconv.FormatUint(uint64(request.Version), 10)+" not supported")
+	}
+
+	response, err := NewHandshake(request.RequestID, ops)
+	if err != nil {
+		return nil, err
+	}
+	if request.Version < response.Version {
+		response.Version = request.Version
+	}
+	if err := response.WriteWithOp(stream); err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+func writeHandshakeError(stream io.Writer, code ErrorCode, message string) error {
+	resp, err := NewErrorPacket(code, message)
+	if err != nil {
+		return err
+	}
+	if err := resp.WriteWithOp(stream); err != nil {
+		return err
+	}
+	return resp
+}
@@ -0,0 +1,212 @@
+package quicpkg
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
+
+func encode(t *testing.T, write func(w io.Writer) error) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	if err := write(buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// must value of packet constructor, fixed test input never overflow
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func TestGoldenBytes(t *testing.T) {
+	handshake := must(NewHandshake(7, []Operation{OperationPackageInfo, OperationPacketReplace}))
+	errPacket, err := NewErrorPacket(ErrorCodeUnknownOperation, "bad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replace := PacketPackageReplace{
+		PacketPackageName:   PacketPackageName{Package: must(NewString[uint16]("app"))},
+		PacketPackageHeader: PacketPackageHeader{Signature: must(NewBytes[uint8]([]byte{0xAA, 0xBB})), Size: 3},
+		Version:             must(NewString[uint8]("1.0.2")),
+		GitHash:             must(NewString[uint8]("ab1")),
+	}
+	response := PacketPackageReplaceResponse{
+		BeforeStdout:       must(NewString[uint32]("b")),
+		AfterStdout:        must(NewString[uint32]("a")),
+		HealthCheck:        HealthCheckFailed,
+		HealthCheckMessage: must(NewString[uint16]("h")),
+		Rollback:           true,
+		RollbackStdout:     must(NewString[uint32]("r")),
+	}
+
+	tests := []struct {
+		name   string
+		write  func(w io.Writer) error
+		golden []byte
+	}{
+		{"client handshake", handshake.WriteWithMagic, []byte{
+			'G', 'B', 'Q', 'P', 0x00, 0x01, 0x00, 0x00, 0x00, 0x07, 0x02, 0x01, 0x03,
+		}},
+		{"server handshake", handshake.WriteWithOp, []byte{
+			0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x07, 0x02, 0x01, 0x03,
+		}},
+		{"error", errPacket.WriteWithOp, []byte{
+			0x00, 0x05, 0x00, 0x03, 'b', 'a', 'd',
+		}},
+		{"replace request", replace.WriteWithOp, []byte{
+			0x03,
+			0x00, 0x03, 'a', 'p', 'p',
+			0x02, 0xAA, 0xBB,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03,
+			0x05, '1', '.', '0', '.', '2',
+			0x03, 'a', 'b', '1',
+		}},
+		{"replace response", response.WriteWithOp, []byte{
+			0x03,
+			0x00, 0x00, 0x00, 0x01, 'b',
+			0x00, 0x00, 0x00, 0x01, 'a',
+			0x02,
+			0x00, 0x01, 'h',
+			0x01,
+			0x00, 0x00, 0x00, 0x01, 'r',
+		}},
+		{"chunks", func(w io.Writer) error {
+			_, err := WriteChunks(w, bytes.NewReader([]byte("abc")))
+			return err
+		}, []byte{
+			0x00, 0x00, 0x00, 0x03, 'a', 'b', 'c', 0x00, 0x00, 0x00, 0x00,
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := encode(t, test.write); !bytes.Equal(got, test.golden) {
+				t.Fatalf("encode\n got % x\nwant % x", got, test.golden)
+			}
+		})
+	}
+}
+
+func TestGoldenDecode(t *testing.T) {
+	var replace PacketPackageReplace
+	if _, err := ReadOp(bytes.NewReader([]byte{0x03})); err != nil {
+		t.Fatal(err)
+	}
+	if err := replace.Read(bytes.NewReader([]byte{
+		0x00, 0x03, 'a', 'p', 'p',
+		0x02, 0xAA, 0xBB,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03,
+		0x05, '1', '.', '0', '.', '2',
+		0x03, 'a', 'b', '1',
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if replace.Package.Data != "app" || replace.Size != 3 || replace.Version.Data != "1.0.2" || replace.GitHash.Data != "ab1" {
+		t.Fatalf("decode replace %+v", replace)
+	}
+
+	op, err := ReadOp(bytes.NewReader([]byte{0x00, 0x05, 0x00, 0x03, 'b', 'a', 'd'}))
+	pktError, ok := err.(*PacketErrorResponse)
+	if op != OperationPacketError || !ok || pktError.ErrCode != ErrorCodeUnknownOperation || pktError.ErrMessage.Data != "bad" {
+		t.Fatalf("decode error %v %v", op, err)
+	}
+
+	out := bytes.NewBuffer(nil)
+	n, err := ReadChunks(bytes.NewReader([]byte{0x00, 0x00, 0x00, 0x03, 'a', 'b', 'c', 0x00, 0x00, 0x00, 0x00}), out, 3)
+	if err != nil || n != 3 || out.String() != "abc" {
+		t.Fatalf("decode chunks %d %q %v", n, out.String(), err)
+	}
+
+	if _, err := ReadChunks(bytes.NewReader([]byte{0x00, 0x00, 0x00, 0x03, 'a', 'b', 'c'}), io.Discard, 2); err == nil {
+		t.Fatal("expect overflow error")
+	}
+}
+
+func TestServerHandshake(t *testing.T) {
+	in := bytes.NewBuffer(nil)
+	client := must(NewHandshake(9, []Operation{OperationPackageGet}))
+	if err := client.WriteWithMagic(in); err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.NewBuffer(nil)
+
+	request, err := ServerHandshake(readWriter{in, out}, []Operation{OperationPackageInfo, OperationPackageGet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.RequestID != 9 || !request.Supports(OperationPackageGet) {
+		t.Fatalf("request %+v", request)
+	}
+
+	golden := []byte{0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x09, 0x02, 0x01, 0x02}
+	if !bytes.Equal(out.Bytes(), golden) {
+		t.Fatalf("reply\n got % x\nwant % x", out.Bytes(), golden)
+	}
+
+	response, err := ClientHandshake(readWriter{bytes.NewReader(golden), io.Discard}, 9, OperationPackageGet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Version != ProtocolVersion {
+		t.Fatalf("version %d", response.Version)
+	}
+
+	if _, err := ClientHandshake(readWriter{bytes.NewReader(golden), io.Discard}, 9, OperationPackageRollback); err == nil {
+		t.Fatal("expect unsupported operation error")
+	}
+}
+
+func TestServerHandshakeLegacyClient(t *testing.T) {
+	// client without handshake start with operation byte
+	in := bytes.NewReader([]byte{0x01, 0x00, 0x03, 'a', 'p', 'p'})
+	out := bytes.NewBuffer(nil)
+
+	if _, err := ServerHandshake(readWriter{in, out}, []Operation{OperationPackageInfo}); err == nil {
+		t.Fatal("expect protocol error")
+	}
+
+	op, err := ReadOp(out)
+	pktError, ok := err.(*PacketErrorResponse)
+	if op != OperationPacketError || !ok || pktError.ErrCode != ErrorCodeProtocol {
+		t.Fatalf("reply %v %v", op, err)
+	}
+}
+
+func TestDataOverflow(t *testing.T) {
+	if _, err := NewString[uint8](strings.Repeat("v", 256)); err == nil {
+		t.Fatal("expect string overflow error")
+	}
+	if _, err := NewBytes[uint8](make([]byte, 256)); err == nil {
+		t.Fatal("expect bytes overflow error")
+	}
+	if data, err := NewString[uint8](strings.Repeat("v", 255)); err != nil || data.Size != 255 {
+		t.Fatalf("size %d err %v", data.Size, err)
+	}
+
+	message := TruncateString[uint16](strings.Repeat("m", 0xFFFF) + "é")
+	if message.Size != 0xFFFF || len(message.Data) != 0xFFFF {
+		t.Fatalf("truncated size %d length %d", message.Size, len(message.Data))
+	}
+	// rune split by cut dropped
+	version := TruncateString[uint8](strings.Repeat("m", 254) + "é")
+	if version.Size != 254 || !utf8.ValidString(version.Data) {
+		t.Fatalf("truncated size %d valid %v", version.Size, utf8.ValidString(version.Data))
+	}
+
+	// size not match data never written
+	if err := WriteData(io.Discard, Data[uint8, string]{Size: 1, Data: "ab"}); err == nil {
+		t.Fatal("expect size mismatch error")
+	}
+}
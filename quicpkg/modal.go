@@ -3,6 +3,7 @@ package quicpkg
 import (
 	"errors"
 	"io"
+	"strconv"
 )
 
 type Operation byte
@@ -12,6 +13,9 @@ const (
 	OperationPackageInfo
 	OperationPackageGet
 	OperationPacketReplace
+	OperationPackageReleases
+	OperationPackageRollback
+	OperationHandshake
 )
 
 func (o Operation) String() string {
@@ -24,6 +28,12 @@ func (o Operation) String() string {
 		return "Package"
 	case OperationPacketReplace:
 		return "PackageReplace"
+	case OperationPackageReleases:
+		return "PackageReleases"
+	case OperationPackageRollback:
+		return "PackageRollback"
+	case OperationHandshake:
+		return "Handshake"
 	}
 
 	return "Unknown"
@@ -34,6 +44,10 @@ type ErrorCode byte
 const (
 	ErrorCodeNotFoundPackage ErrorCode = iota + 1
 	ErrorCodeSystem
+	ErrorCodeNotFoundRelease
+	ErrorCodeProtocol
+	ErrorCodeUnknownOperation
+	ErrorCodePermissionDenied
 )
 
 type PacketErrorResponse struct {
@@ -56,6 +70,10 @@ func NewErrorPacket(code ErrorCode, message string) (*PacketErrorResponse, error
 	}, nil
 }
 
+func (p *PacketErrorResponse) Error() string {
+	return "[" + strconv.FormatUint(uint64(p.ErrCode), 10) + "] " + p.ErrMessage.Data
+}
+
 func (p *PacketErrorResponse) Read(stream io.Reader) error {
 	var errCode byte
 	if err := Read[byte](stream, &errCode); err != nil {
@@ -85,8 +103,18 @@ func (p *PacketErrorResponse) WriteWithOp(stream io.Writer) error {
 	return p.Write(stream)
 }
 
-type PacketReplaceRequest struct {
-	Package   Data[uint16, string]
-	Signature Data[uint8, []byte]
-	Binary    Data[uint64, []byte]
+// ReadOp read response operation, error packet return as error
+func ReadOp(stream io.Reader) (Operation, error) {
+	var op byte
+	if err := Read(stream, &op); err != nil {
+		return 0, err
+	}
+	if op == OperationPacketError {
+		var pktError PacketErrorResponse
+		if err := pktError.Read(stream); err != nil {
+			return 0, err
+		}
+		return Operation(op), &pktError
+	}
+	return Operation(op), nil
 }
@@ -1,11 +1,22 @@
 package quicpkg
 
-import "io"
+import (
+	"errors"
+	"io"
+)
 
 type PacketPackageName struct {
 	Package Data[uint16, string]
 }
 
+func NewPackageName(name string) (PacketPackageName, error) {
+	pkg, err := NewString[uint16](name)
+	if err != nil {
+		return PacketPackageName{}, errors.New("package name " + err.Error())
+	}
+	return PacketPackageName{Package: pkg}, nil
+}
+
 func (p *PacketPackageName) Read(stream io.Reader) error {
 	if err := ReadData(stream, &p.Package); err != nil {
 		return err
@@ -19,22 +30,34 @@ func (p *PacketPackageName) Write(stream io.Writer) error {
 	return nil
 }
 func (p *PacketPackageName) WriteWithOp(stream io.Writer) error {
-	if err := Write[byte](stream, OperationPackageInfo); err != nil {
+	return p.WriteWith(stream, OperationPackageInfo)
+}
+func (p *PacketPackageName) WriteWith(stream io.Writer, op Operation) error {
+	if err := Write(stream, byte(op)); err != nil {
 		return err
 	}
 	return p.Write(stream)
 }
 
+// PacketPackageReplace binary follow as chunks after server accept the request
 type PacketPackageReplace struct {
 	PacketPackageName
-	PacketPackage
+	PacketPackageHeader
+	Version Data[uint8, string]
+	GitHash Data[uint8, string]
 }
 
 func (p *PacketPackageReplace) Read(stream io.Reader) error {
 	if err := p.PacketPackageName.Read(stream); err != nil {
 		return err
 	}
-	if err := p.PacketPackage.Read(stream); err != nil {
+	if err := p.PacketPackageHeader.Read(stream); err != nil {
+		return err
+	}
+	if err := ReadData(stream, &p.Version); err != nil {
+		return err
+	}
+	if err := ReadData(stream, &p.GitHash); err != nil {
 		return err
 	}
 	return nil
@@ -43,7 +66,13 @@ func (p *PacketPackageReplace) Write(stream io.Writer) error {
 	if err := p.PacketPackageName.Write(stream); err != nil {
 		return err
 	}
-	if err := p.PacketPackage.Write(stream); err != nil {
+	if err := p.PacketPackageHeader.Write(stream); err != nil {
+		return err
+	}
+	if err := WriteData(stream, p.Version); err != nil {
+		return err
+	}
+	if err := WriteData(stream, p.GitHash); err != nil {
 		return err
 	}
 	return nil
@@ -86,46 +115,42 @@ func (p *PacketPackageInfo) WriteWithOp(stream io.Writer) error {
 	return p.Write(stream)
 }
 
-type PacketPackage struct {
-	Signature Data[uint8, []byte]
-	Data      Data[uint64, []byte]
+type HealthCheckStatus byte
+
+const (
+	HealthCheckNone HealthCheckStatus = iota
+	HealthCheckPassed
+	HealthCheckFailed
+)
+
+type PacketPackageReplaceResponse struct {
+	BeforeStdout       Data[uint32, string]
+	AfterStdout        Data[uint32, string]
+	HealthCheck        HealthCheckStatus
+	HealthCheckMessage Data[uint16, string]
+	Rollback           bool
+	RollbackStdout     Data[uint32, string]
 }
 
-func (p *PacketPackage) Read(stream io.Reader) error {
-	if err := ReadData(stream, &p.Signature); err != nil {
-		return err
-	}
-	if err := ReadData(stream, &p.Data); err != nil {
+func (p *PacketPackageReplaceResponse) Read(stream io.Reader) error {
+	if err := ReadData(stream, &p.BeforeStdout); err != nil {
 		return err
 	}
-	return nil
-}
-func (p *PacketPackage) Write(stream io.Writer) error {
-	if err := WriteData(stream, p.Signature); err != nil {
+	if err := ReadData(stream, &p.AfterStdout); err != nil {
 		return err
 	}
-	if err := WriteData(stream, p.Data); err != nil {
+	var healthCheck byte
+	if err := Read(stream, &healthCheck); err != nil {
 		return err
 	}
-	return nil
-}
-func (p *PacketPackage) WriteWithOp(stream io.Writer) error {
-	if err := Write[byte](stream, OperationPackageGet); err != nil {
+	p.HealthCheck = HealthCheckStatus(healthCheck)
+	if err := ReadData(stream, &p.HealthCheckMessage); err != nil {
 		return err
 	}
-	return p.Write(stream)
-}
-
-type PacketPackageReplaceResponse struct {
-	BeforeStdout Data[uint32, string]
-	AfterStdout  Data[uint32, string]
-}
-
-func (p *PacketPackageReplaceResponse) Read(stream io.Reader) error {
-	if err := ReadData(stream, &p.BeforeStdout); err != nil {
+	if err := Read(stream, &p.Rollback); err != nil {
 		return err
 	}
-	if err := ReadData(stream, &p.AfterStdout); err != nil {
+	if err := ReadData(stream, &p.RollbackStdout); err != nil {
 		return err
 	}
 	return nil
@@ -137,6 +162,18 @@ func (p *PacketPackageReplaceResponse) Write(stream io.Writer) error {
 	if err := WriteData(stream, p.AfterStdout); err != nil {
 		return err
 	}
+	if err := Write(stream, byte(p.HealthCheck)); err != nil {
+		return err
+	}
+	if err := WriteData(stream, p.HealthCheckMessage); err != nil {
+		return err
+	}
+	if err := Write(stream, p.Rollback); err != nil {
+		return err
+	}
+	if err := WriteData(stream, p.RollbackStdout); err != nil {
+		return err
+	}
 	return nil
 }
 func (p *PacketPackageReplaceResponse) WriteWithOp(stream io.Writer) error {
@@ -145,3 +182,8 @@ func (p *PacketPackageReplaceResponse) WriteWithOp(stream io.Writer) error {
 	}
 	return p.Write(stream)
 }
+
+// WriteReplaceAccept tell client start send binary chunks
+func WriteReplaceAccept(stream io.Writer) error {
+	return Write[byte](stream, OperationPacketReplace)
+}
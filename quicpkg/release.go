@@ -0,0 +1,121 @@
+package quicpkg
+
+import (
+	"errors"
+	"io"
+)
+
+type PacketRelease struct {
+	Version   Data[uint8, string]
+	GitHash   Data[uint8, string]
+	Timestamp int64
+	Signature Data[uint8, []byte]
+}
+
+func (p *PacketRelease) Read(stream io.Reader) error {
+	if err := ReadData(stream, &p.Version); err != nil {
+		return err
+	}
+	if err := ReadData(stream, &p.GitHash); err != nil {
+		return err
+	}
+	if err := Read(stream, &p.Timestamp); err != nil {
+		return err
+	}
+	if err := ReadData(stream, &p.Signature); err != nil {
+		return err
+	}
+	return nil
+}
+func (p *PacketRelease) Write(stream io.Writer) error {
+	if err := WriteData(stream, p.Version); err != nil {
+		return err
+	}
+	if err := WriteData(stream, p.GitHash); err != nil {
+		return err
+	}
+	if err := Write(stream, p.Timestamp); err != nil {
+		return err
+	}
+	if err := WriteData(stream, p.Signature); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PacketPackageReleases newest release first
+type PacketPackageReleases struct {
+	Current  Data[uint8, []byte]
+	Releases []PacketRelease
+}
+
+func (p *PacketPackageReleases) Read(stream io.Reader) error {
+	if err := ReadData(stream, &p.Current); err != nil {
+		return err
+	}
+	var count uint16
+	if err := Read(stream, &count); err != nil {
+		return err
+	}
+	p.Releases = make([]PacketRelease, count)
+	for i := range p.Releases {
+		if err := p.Releases[i].Read(stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (p *PacketPackageReleases) Write(stream io.Writer) error {
+	if len(p.Releases) > 0xFFFF {
+		return errors.New("releases length overflow")
+	}
+	if err := WriteData(stream, p.Current); err != nil {
+		return err
+	}
+	if err := Write(stream, uint16(len(p.Releases))); err != nil {
+		return err
+	}
+	for i := range p.Releases {
+		if err := p.Releases[i].Write(stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (p *PacketPackageReleases) WriteWithOp(stream io.Writer) error {
+	if err := Write[byte](stream, OperationPackageReleases); err != nil {
+		return err
+	}
+	return p.Write(stream)
+}
+
+// PacketPackageRollback empty version mean the release before current
+type PacketPackageRollback struct {
+	PacketPackageName
+	Version Data[uint8, string]
+}
+
+func (p *PacketPackageRollback) Read(stream io.Reader) error {
+	if err := p.PacketPackageName.Read(stream); err != nil {
+		return err
+	}
+	if err := ReadData(stream, &p.Version); err != nil {
+		return err
+	}
+	return nil
+}
+func (p *PacketPackageRollback) Write(stream io.Writer) error {
+	if err := p.PacketPackageName.Write(stream); err != nil {
+		return err
+	}
+	if err := WriteData(stream, p.Version); err != nil {
+		return err
+	}
+	return nil
+}
+func (p *PacketPackageRollback) WriteWithOp(stream io.Writer) error {
+	if err := Write[byte](stream, OperationPackageRollback); err != nil {
+		return err
+	}
+	return p.Write(stream)
+}
@@ -0,0 +1,93 @@
+package quicpkg
+
+import (
+	"errors"
+	"io"
+)
+
+const ChunkSize = 64 * 1024
+
+type PacketPackageHeader struct {
+	Signature Data[uint8, []byte]
+	Size      uint64
+}
+
+func (p *PacketPackageHeader) Read(stream io.Reader) error {
+	if err := ReadData(stream, &p.Signature); err != nil {
+		return err
+	}
+	if err := Read(stream, &p.Size); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *PacketPackageHeader) Write(stream io.Writer) error {
+	if err := WriteData(stream, p.Signature); err != nil {
+		return err
+	}
+	if err := Write(stream, p.Size); err != nil {
+		return err
+	}
+	return nil
+}
+
+// WriteWithOp response of package get, binary follow as chunks
+func (p *PacketPackageHeader) WriteWithOp(stream io.Writer) error {
+	if err := Write[byte](stream, OperationPackageGet); err != nil {
+		return err
+	}
+	return p.Write(stream)
+}
+
+// WriteChunks copy r to stream as size prefixed chunks, a zero size chunk mark the end
+func WriteChunks(stream io.Writer, r io.Reader) (uint64, error) {
+	var total uint64
+	buf := make([]byte, ChunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := WriteData(stream, Data[uint32, []byte]{Size: uint32(n), Data: buf[:n]}); err != nil {
+				return total, err
+			}
+			total += uint64(n)
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return total, err
+		}
+	}
+
+	return total, Write[uint32](stream, 0)
+}
+
+// ReadChunks copy chunks from stream to w until the end chunk, fail when more than limit bytes arrive
+func ReadChunks(stream io.Reader, w io.Writer, limit uint64) (uint64, error) {
+	var total uint64
+	buf := make([]byte, ChunkSize)
+	for {
+		var size uint32
+		if err := Read(stream, &size); err != nil {
+			return total, err
+		}
+		if size == 0 {
+			return total, nil
+		}
+		if size > ChunkSize {
+			return total, errors.New("chunk size overflow")
+		}
+		total += uint64(size)
+		if total > limit {
+			return total, errors.New("data overflow")
+		}
+		chunk := buf[:size]
+		if _, err := io.ReadFull(stream, chunk); err != nil {
+			return total, err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return total, err
+		}
+	}
+}
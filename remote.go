@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/lucas-clemente/quic-go"
+	"gobuilder/log"
+	"gobuilder/quicpkg"
+	"io/ioutil"
+	"sync/atomic"
+	"time"
+)
+
+func DeployTLSConfig() (*tls.Config, error) {
+	tlsCert, err := BuildConfig.GetTlsCert()
+	if err != nil {
+		return nil, err
+	}
+
+	pem, err := ioutil.ReadFile(BuildConfig.CA)
+	if err != nil {
+		return nil, err
+	}
+	caPool := x509.NewCertPool()
+	caPool.AppendCertsFromPEM(pem)
+
+	serverName := BuildConfig.ServerName
+	if serverName == "" {
+		serverName = "gobuilder-quic"
+	}
+
+	return &tls.Config{
+		RootCAs:      caPool,
+		Certificates: []tls.Certificate{tlsCert},
+		NextProtos:   []string{"gobuilder-quic"},
+		ServerName:   serverName,
+	}, nil
+}
+
+func DialDeploy(address string) (quic.Connection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	tlsConfig, err := DeployTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Debug("dial", address)
+
+	// server actions and health check may run longer than idle timeout without traffic
+	return quic.DialAddrContext(ctx, address, tlsConfig, &quic.Config{
+		KeepAlive: true,
+	})
+}
+
+var requestID uint32
+
+// OpenRequest open stream and negotiate protocol for op
+func OpenRequest(remote quic.Connection, op quicpkg.Operation) (quic.Stream, error) {
+	stream, err := remote.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := quicpkg.ClientHandshake(stream, atomic.AddUint32(&requestID, 1), op); err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
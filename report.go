@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	ReportFormatJSON  = "json"
+	ReportFormatJUnit = "junit"
+)
+
+type ReportDeploy struct {
+	Target   string  `json:"target"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+type ReportGate struct {
+	Name     string   `json:"name"`
+	Command  string   `json:"command"`
+	Status   string   `json:"status"`
+	Coverage *float64 `json:"coverage,omitempty"`
+	Duration float64  `json:"duration"`
+	Output   string   `json:"output,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type ReportPackage struct {
+	Name       string         `json:"name"`
+	Output     string         `json:"output"`
+	Package    string         `json:"package"`
+	OS         string         `json:"os"`
+	Arch       string         `json:"arch"`
+	Status     string         `json:"status"`
+	OldVersion string         `json:"old-version"`
+	NewVersion string         `json:"new-version"`
+	GitHash    string         `json:"git-hash,omitempty"`
+	GitBranch  string         `json:"git-branch,omitempty"`
+	Duration   float64        `json:"duration"`
+	Artifact   string         `json:"artifact,omitempty"`
+	Size       int64          `json:"size,omitempty"`
+	Sha256     string         `json:"sha256,omitempty"`
+	Archives   []ArchiveFile  `json:"archives,omitempty"`
+	Gates      []ReportGate   `json:"pre-build,omitempty"`
+	Deploys    []ReportDeploy `json:"deploys,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type Report struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Duration  float64         `json:"duration"`
+	Failed    bool            `json:"failed"`
+	Packages  []ReportPackage `json:"packages"`
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func NewReport(start time.Time, results []TaskResult) *Report {
+	report := &Report{
+		Timestamp: start,
+		Duration:  time.Since(start).Seconds(),
+		Failed:    AnyTaskFailed(results),
+		Packages:  make([]ReportPackage, 0, len(results)),
+	}
+
+	for _, r := range results {
+		p := ReportPackage{
+			Name:       r.Name,
+			Output:     r.Output,
+			Package:    r.Package,
+			OS:         r.OS,
+			Arch:       r.Arch,
+			Status:     r.Status,
+			OldVersion: r.OldVersion,
+			NewVersion: r.NewVersion,
+			GitHash:    r.GitHash,
+			GitBranch:  r.GitBranch,
+			Duration:   r.Duration.Seconds(),
+			Artifact:   r.Artifact,
+			Size:       r.Size,
+			Sha256:     r.Sha256,
+			Archives:   r.Archives,
+			Error:      errorText(r.Err),
+		}
+		for _, g := range r.Gates {
+			gate := ReportGate{
+				Name:     g.Name,
+				Command:  strings.Join(g.Command, " "),
+				Status:   "passed",
+				Duration: g.Duration.Seconds(),
+				Output:   g.Output,
+				Error:    errorText(g.Err),
+			}
+			if g.Err != nil {
+				gate.Status = "failed"
+			}
+			if g.Coverage >= 0 {
+				coverage := g.Coverage
+				gate.Coverage = &coverage
+			}
+			p.Gates = append(p.Gates, gate)
+		}
+		for _, d := range r.Deploys {
+			p.Deploys = append(p.Deploys, ReportDeploy{
+				Target:   d.Target,
+				Status:   d.Status,
+				Duration: d.Duration.Seconds(),
+				Error:    errorText(d.Err),
+			})
+		}
+		report.Packages = append(report.Packages, p)
+	}
+
+	return report
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",cdata"`
+}
+
+func junitSeconds(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
+
+func (r *Report) JUnit() junitTestSuites {
+	suite := junitTestSuite{
+		Name:      "gobuilder",
+		Tests:     len(r.Packages),
+		Time:      junitSeconds(r.Duration),
+		Timestamp: r.Timestamp.Format(time.RFC3339),
+	}
+
+	for _, p := range r.Packages {
+		c := junitTestCase{
+			Name:      p.Output,
+			ClassName: p.Package,
+			Time:      junitSeconds(p.Duration),
+		}
+
+		out := []string{
+			"target: " + p.OS + "/" + p.Arch,
+			"version: " + p.OldVersion + " -> " + p.NewVersion,
+		}
+		if p.GitHash != "" {
+			out = append(out, "git: "+p.GitHash+"/"+p.GitBranch)
+		}
+		if p.Artifact != "" {
+			out = append(out, fmt.Sprintf("artifact: %s %d bytes sha256 %s", p.Artifact, p.Size, p.Sha256))
+		}
+		for _, a := range p.Archives {
+			out = append(out, "archive: "+a.Path+" sha256 "+a.Sha256)
+		}
+		for _, g := range p.Gates {
+			line := fmt.Sprintf("pre-build: %s %s %.3fs", g.Name, g.Status, g.Duration)
+			if g.Coverage != nil {
+				line += fmt.Sprintf(" coverage %.1f%%", *g.Coverage)
+			}
+			out = append(out, line, "$ "+g.Command)
+			if output := strings.TrimSpace(g.Output); output != "" {
+				out = append(out, output)
+			}
+		}
+		for _, d := range p.Deploys {
+			line := fmt.Sprintf("deploy: %s %s %.3fs", d.Target, d.Status, d.Duration)
+			if d.Error != "" {
+				line += " " + d.Error
+			}
+			out = append(out, line)
+		}
+		c.SystemOut = &junitOutput{Text: strings.Join(out, "\n")}
+
+		switch p.Status {
+		case TaskStatusFailed:
+			suite.Failures++
+			c.Failure = &junitMessage{Message: strings.SplitN(p.Error, "\n", 2)[0], Text: p.Error}
+		case TaskStatusSkipped:
+			suite.Skipped++
+			c.Skipped = &junitMessage{Message: p.Error}
+		}
+
+		suite.Cases = append(suite.Cases, c)
+	}
+
+	return junitTestSuites{Suites: []junitTestSuite{suite}}
+}
+
+func DefaultReportPath(format string) string {
+	if format == ReportFormatJUnit {
+		return "gobuilder-report.xml"
+	}
+	return "gobuilder-report.json"
+}
+
+// WriteReport write report of all packages to path in json or junit format
+func WriteReport(format, path string, report *Report) error {
+	var (
+		data []byte
+		err  error
+	)
+	switch format {
+	case ReportFormatJSON:
+		data, err = json.MarshalIndent(report, "", "  ")
+	case ReportFormatJUnit:
+		data, err = xml.MarshalIndent(report.JUnit(), "", "  ")
+		data = append([]byte(xml.Header), data...)
+	default:
+		return errors.New("report format `" + format + "` invalid, json or junit")
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
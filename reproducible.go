@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"gobuilder/log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReproducibleProjectDir docker mount path of module in reproducible mode
+const ReproducibleProjectDir = "/go/src/project"
+
+// SourceDateEpoch time of source, `SOURCE_DATE_EPOCH` or last git commit time
+func SourceDateEpoch() (time.Time, error) {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return time.Time{}, errors.New("`SOURCE_DATE_EPOCH` invalid " + epoch)
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	cmd := NewGitCommand("log", "-1", "--format=%ct")
+	if err := cmd.Start(); err != nil {
+		return time.Time{}, err
+	}
+	if err := cmd.Wait(); err != nil {
+		return time.Time{}, errors.New("resolve commit time failed " + err.Error())
+	}
+
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(cmd.Stdout())), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// verifySource HEAD and working tree same as artifact built from, rebuild of other sources never match
+func verifySource(t Task, info *BuildInfo) error {
+	if info.GitHash == "" {
+		return nil
+	}
+
+	head, err := gitOutput("rev-parse", "HEAD")
+	if err != nil {
+		return errors.New("resolve git HEAD failed " + err.Error())
+	}
+	if !strings.HasPrefix(head, info.GitHash) {
+		return errors.New("artifact built from commit " + info.GitHash + " but HEAD is " + shortHex(head) + ", checkout it before verify")
+	}
+
+	dirty, err := GitDirty(t.Package)
+	if err != nil {
+		return errors.New("resolve git status failed " + err.Error())
+	}
+	switch {
+	case dirty && !info.GitDirty:
+		return errors.New("working tree modified since artifact built from clean " + info.GitHash + ", stash changes before verify")
+	case !dirty && info.GitDirty:
+		return errors.New("artifact built from modified working tree of " + info.GitHash + ", sources not reproducible")
+	case dirty:
+		log.Warn("artifact built from modified working tree, verify with current changes", t.Output)
+	}
+	return nil
+}
+
+// VerifyPackage rebuild task with build info of artifact in a temp dest and compare with artifact
+func VerifyPackage(t Task, info *BuildInfo, artifact string) (bool, error) {
+	if err := verifySource(t, info); err != nil {
+		return false, err
+	}
+
+	expect, _, err := FileSignature(artifact)
+	if err != nil {
+		return false, err
+	}
+
+	dest := t.Package.Dest
+	if dest == "" {
+		dest = "."
+	}
+	// temp dir under dest so docker build see it in mounted module
+	tempDir, err := os.MkdirTemp(dest, ".verify-")
+	if err != nil {
+		return false, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	pkg := *t.Package
+	pkg.Dest = tempDir
+
+	if err := GoBuildWith(t.Output, &pkg, info); err != nil {
+		return false, err
+	}
+
+	actual, _, err := FileSignature(filepath.Join(tempDir, t.Output))
+	if err != nil {
+		return false, err
+	}
+
+	log.Debug("verify", artifact, hex.EncodeToString(expect), "rebuild", hex.EncodeToString(actual))
+
+	return bytes.Equal(expect, actual), nil
+}
+
+func VerifyHandle(args []string) error {
+	fs := NewFlagSet("verify")
+	artifact := fs.String("a", "", "artifact path, default <dest>/<output>")
+	versionString := fs.String("version", "", "version of artifact, default from artifact metadata")
+
+	names, err := ParseCommand(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(names) != 1 {
+		return errors.New("usage: gobuilder verify <pkg> [-a artifact] [-version 1.2.3]")
+	}
+
+	t, err := LookupTask(names[0])
+	if err != nil {
+		return err
+	}
+	if !t.Package.Reproducible {
+		return errors.New("package `" + t.Name + "` without `reproducible`")
+	}
+	if *artifact == "" {
+		*artifact = filepath.Join(t.Package.Dest, t.Output)
+	}
+
+	// version, build stamp and git ref the artifact built with
+	var info *BuildInfo
+	meta, err := ReadArtifactMeta(MetaPath(*artifact))
+	switch {
+	case err == nil:
+		info = meta.BuildInfo
+	case !os.IsNotExist(err):
+		return err
+	case *versionString == "" && t.Package.ConfigVersion():
+		return errors.New("artifact metadata `" + MetaPath(*artifact) + "` not found, version required with -version")
+	default:
+		if !t.Package.ConfigVersion() {
+			if err := t.Package.ResolveVersion(); err != nil {
+				return err
+			}
+		}
+		if info, err = NewBuildInfo(t.Package); err != nil {
+			return err
+		}
+	}
+	if *versionString != "" {
+		version, err := ParseVersion(*versionString)
+		if err != nil {
+			return err
+		}
+		info.Version = version.String()
+	}
+
+	same, err := VerifyPackage(t, info, *artifact)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return errors.New("rebuild of " + info.Version + " not match `" + *artifact + "`")
+	}
+
+	log.Ok("verify completed", info.Version, *artifact, "reproduced")
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	TaskStatusBuilt     = "built"
+	TaskStatusDeployed  = "deployed"
+	TaskStatusUnchanged = "unchanged"
+	TaskStatusSkipped   = "skipped"
+	TaskStatusFailed    = "failed"
+)
+
+var ErrCanceled = errors.New("canceled by fail fast")
+
+type TaskResult struct {
+	Name        string
+	Output      string
+	Package     string
+	OS          string
+	Arch        string
+	Status      string
+	OldVersion  string
+	NewVersion  string
+	GitHash     string
+	GitBranch   string
+	Artifact    string
+	Size        int64
+	Sha256      string
+	Archives    []ArchiveFile
+	Fingerprint string
+	Rebuilt     bool // binary built in this run, artifact of earlier build when deployed only
+	Gates       []GateResult
+	Deploys     []DeployResult
+	Duration    time.Duration
+	Err         error
+}
+
+func NewTaskResult(t Task, status string, err error) TaskResult {
+	goOS, goArch := t.Package.Target()
+	return TaskResult{
+		Name:       t.Name,
+		Output:     t.Output,
+		Package:    t.Package.Package,
+		OS:         goOS,
+		Arch:       goArch,
+		Status:     status,
+		OldVersion: t.Package.BuildVersion().String(),
+		NewVersion: t.Package.BuildVersion().String(),
+		Err:        err,
+	}
+}
+
+func (r TaskResult) Failed() bool {
+	return r.Status == TaskStatusFailed
+}
+
+// Succeeded dependents of task can go on
+func (r TaskResult) Succeeded() bool {
+	return r.Status == TaskStatusBuilt || r.Status == TaskStatusDeployed || r.Status == TaskStatusUnchanged
+}
+
+func AnyTaskFailed(results []TaskResult) bool {
+	for _, r := range results {
+		if r.Failed() {
+			return true
+		}
+	}
+	return false
+}
+
+func PrintBuildSummary(results []TaskResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PACKAGE\tTARGET\tVERSION\tRESULT\tDURATION\tERROR")
+	for _, r := range results {
+		var errMessage string
+		if r.Err != nil {
+			errMessage = strings.SplitN(r.Err.Error(), "\n", 2)[0]
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			r.Output, r.OS+"/"+r.Arch, r.OldVersion, r.Status, r.Duration.Round(time.Millisecond), errMessage)
+	}
+	return w.Flush()
+}
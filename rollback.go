@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/lucas-clemente/quic-go"
+	"gobuilder/log"
+	"gobuilder/quicpkg"
+	"strconv"
+	"time"
+)
+
+func ListRemoteReleases(remote quic.Connection, name string) (*quicpkg.PacketPackageReleases, error) {
+	request, err := quicpkg.NewPackageName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := OpenRequest(remote, quicpkg.OperationPackageReleases)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := request.WriteWith(stream, quicpkg.OperationPackageReleases); err != nil {
+		return nil, err
+	}
+
+	if _, err := quicpkg.ReadOp(stream); err != nil {
+		return nil, err
+	}
+
+	var response quicpkg.PacketPackageReleases
+	if err := response.Read(stream); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+func RollbackTarget(target, name, version string) error {
+	remote, err := DialDeploy(target)
+	if err != nil {
+		return err
+	}
+	defer remote.CloseWithError(0, "")
+
+	releases, err := ListRemoteReleases(remote, name)
+	if err != nil {
+		return err
+	}
+
+	log.Log("releases", target, "-", name)
+	for _, release := range releases.Releases {
+		mark := " "
+		if bytes.Equal(release.Signature.Data, releases.Current.Data) {
+			mark = "*"
+		}
+		log.Log(mark, release.Version.Data, release.GitHash.Data,
+			time.Unix(release.Timestamp, 0).Format(time.RFC3339),
+			shortHash(release.Signature.Data))
+	}
+
+	packageName, err := quicpkg.NewPackageName(name)
+	if err != nil {
+		return err
+	}
+	packageVersion, err := quicpkg.NewString[uint8](version)
+	if err != nil {
+		return errors.New("version " + err.Error())
+	}
+	request := quicpkg.PacketPackageRollback{
+		PacketPackageName: packageName,
+		Version:           packageVersion,
+	}
+
+	stream, err := OpenRequest(remote, quicpkg.OperationPackageRollback)
+	if err != nil {
+		return err
+	}
+	if err := request.WriteWithOp(stream); err != nil {
+		return err
+	}
+
+	if _, err := quicpkg.ReadOp(stream); err != nil {
+		return err
+	}
+
+	var response quicpkg.PacketPackageReplaceResponse
+	if err := response.Read(stream); err != nil {
+		return err
+	}
+
+	log.Debug(name, "-", target, "-", "bstdout", response.BeforeStdout.Data, "astdout", response.AfterStdout.Data)
+
+	if response.Rollback {
+		return RollbackError(&response)
+	}
+
+	return nil
+}
+
+func RollbackHandle(args []string) error {
+	args, err := ParseCommand(NewFlagSet("rollback"), args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New("usage: gobuilder rollback <pkg> [version]")
+	}
+
+	name := args[0]
+	var version string
+	if len(args) > 1 {
+		version = args[1]
+	}
+
+	t, err := LookupTask(name)
+	if err != nil {
+		return err
+	}
+	name = t.Output
+
+	targets := BuildConfig.ResolveTargets(t.Package.Deploy)
+	if len(targets) == 0 {
+		return errors.New("package `" + name + "` without `deploy`")
+	}
+
+	description := version
+	if description == "" {
+		description = "previous release"
+	}
+
+	failed := 0
+	for _, target := range targets {
+		if err := RollbackTarget(target, name, version); err != nil {
+			log.Error("rollback failed", target, "-", name, err)
+			failed++
+			continue
+		}
+		log.Ok("rollback completed", description, target, "-", name)
+	}
+
+	if failed > 0 {
+		return errors.New("rollback failed on " + strconv.Itoa(failed) + "/" + strconv.Itoa(len(targets)) + " hosts")
+	}
+
+	return nil
+}
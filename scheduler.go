@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+type SkippedError struct {
+	Dependency string
+}
+
+func (e *SkippedError) Error() string {
+	return "dependency `" + e.Dependency + "` failed"
+}
+
+// TaskGraph task depend on every task of package listed in `depends-on`
+type TaskGraph struct {
+	Tasks      []Task
+	dependents [][]int
+	indegree   []int
+}
+
+// SelectTasks tasks of named packages and their dependencies, all packages when names empty,
+// version of each package resolved
+func SelectTasks(names []string) ([]Task, error) {
+	if len(names) == 0 {
+		for name := range BuildConfig.Packages {
+			names = append(names, name)
+		}
+	}
+
+	selected := make(map[string]bool)
+	var visit func(name, from string) error
+	visit = func(name, from string) error {
+		if selected[name] {
+			return nil
+		}
+		pkg, ok := BuildConfig.Packages[name]
+		if !ok {
+			if from != "" {
+				return errors.New("package `" + from + "` depends on unknown package `" + name + "`")
+			}
+			return NotFoundError(name)
+		}
+		selected[name] = true
+		for _, dep := range pkg.DependsOn {
+			if err := visit(dep, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	var tasks []Task
+	for name := range selected {
+		// resolve before expand, targets share version
+		if err := BuildConfig.Packages[name].ResolveVersion(); err != nil {
+			return nil, errors.New("package `" + name + "` " + err.Error())
+		}
+		expanded, err := BuildConfig.Packages[name].Tasks(name)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, expanded...)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Output < tasks[j].Output
+	})
+
+	return tasks, nil
+}
+
+func NewTaskGraph(tasks []Task) (*TaskGraph, error) {
+	g := &TaskGraph{
+		Tasks:      tasks,
+		dependents: make([][]int, len(tasks)),
+		indegree:   make([]int, len(tasks)),
+	}
+
+	byName := make(map[string][]int)
+	for i, t := range tasks {
+		byName[t.Name] = append(byName[t.Name], i)
+	}
+
+	for i, t := range tasks {
+		for _, dep := range t.Package.DependsOn {
+			prerequisites, ok := byName[dep]
+			if !ok {
+				return nil, errors.New("package `" + t.Name + "` depends on unselected package `" + dep + "`")
+			}
+			for _, p := range prerequisites {
+				g.dependents[p] = append(g.dependents[p], i)
+				g.indegree[i]++
+			}
+		}
+	}
+
+	if cycle := g.findCycle(); cycle != nil {
+		return nil, errors.New("dependency cycle " + strings.Join(cycle, " -> "))
+	}
+
+	return g, nil
+}
+
+func (g *TaskGraph) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(g.Tasks))
+	var stack []int
+
+	var visit func(i int) []string
+	visit = func(i int) []string {
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, d := range g.dependents[i] {
+			if state[d] == visiting {
+				var cycle []string
+				for j := len(stack) - 1; j >= 0; j-- {
+					cycle = append([]string{g.Tasks[stack[j]].Name}, cycle...)
+					if stack[j] == d {
+						break
+					}
+				}
+				return append(cycle, g.Tasks[d].Name)
+			}
+			if state[d] == unvisited {
+				if cycle := visit(d); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+		return nil
+	}
+
+	for i := range g.Tasks {
+		if state[i] == unvisited {
+			if cycle := visit(i); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// Run process ready tasks in parallel, dependents of failed task skipped with SkippedError,
+// when failFast first failure cancel all tasks not started yet
+func (g *TaskGraph) Run(parallel int, failFast bool, process func(Task) TaskResult) []TaskResult {
+	type finished struct {
+		index  int
+		result TaskResult
+	}
+
+	results := make([]TaskResult, len(g.Tasks))
+	settled := make([]bool, len(g.Tasks))
+	dispatched := make([]bool, len(g.Tasks))
+	remaining := append([]int(nil), g.indegree...)
+	pending := len(g.Tasks)
+	var canceled int32
+
+	ready := make(chan int, len(g.Tasks))
+	done := make(chan finished)
+
+	for i := 0; i < parallel; i++ {
+		go func() {
+			for index := range ready {
+				t := g.Tasks[index]
+				if atomic.LoadInt32(&canceled) == 1 {
+					done <- finished{index: index, result: NewTaskResult(t, TaskStatusSkipped, ErrCanceled)}
+					continue
+				}
+				done <- finished{index: index, result: process(t)}
+			}
+		}()
+	}
+
+	dispatch := func(i int) {
+		dispatched[i] = true
+		ready <- i
+	}
+
+	for i, n := range remaining {
+		if n == 0 {
+			dispatch(i)
+		}
+	}
+
+	settle := func(i int, err error) {
+		settled[i] = true
+		results[i] = NewTaskResult(g.Tasks[i], TaskStatusSkipped, err)
+		pending--
+	}
+
+	var skip func(i int, dependency string)
+	skip = func(i int, dependency string) {
+		if settled[i] {
+			return
+		}
+		settle(i, &SkippedError{Dependency: dependency})
+		for _, d := range g.dependents[i] {
+			skip(d, g.Tasks[i].Name)
+		}
+	}
+
+	for pending > 0 {
+		f := <-done
+		settled[f.index] = true
+		results[f.index] = f.result
+		pending--
+
+		succeeded := f.result.Succeeded()
+		if f.result.Failed() && failFast && atomic.CompareAndSwapInt32(&canceled, 0, 1) {
+			for i := range g.Tasks {
+				if !settled[i] && !dispatched[i] {
+					settle(i, ErrCanceled)
+				}
+			}
+		}
+
+		for _, d := range g.dependents[f.index] {
+			if !succeeded {
+				skip(d, g.Tasks[f.index].Name)
+				continue
+			}
+			remaining[d]--
+			if remaining[d] == 0 && !settled[d] {
+				dispatch(d)
+			}
+		}
+	}
+	close(ready)
+
+	return results
+}
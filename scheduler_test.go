@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// graphTasks task of each `name:dep,dep` spec, output same as name
+func graphTasks(specs ...string) []Task {
+	var tasks []Task
+	for _, spec := range specs {
+		name, deps, _ := strings.Cut(spec, ":")
+		pkg := &GoBuilderPackage{Package: "x/" + name}
+		if deps != "" {
+			pkg.DependsOn = strings.Split(deps, ",")
+		}
+		tasks = append(tasks, Task{Name: name, Output: name, Package: pkg})
+	}
+	return tasks
+}
+
+// fakeProcess fail named tasks, record order tasks processed
+type fakeProcess struct {
+	fail  map[string]bool
+	lock  sync.Mutex
+	order []string
+}
+
+func (p *fakeProcess) process(t Task) TaskResult {
+	p.lock.Lock()
+	p.order = append(p.order, t.Name)
+	p.lock.Unlock()
+
+	if p.fail[t.Name] {
+		return NewTaskResult(t, TaskStatusFailed, errors.New("build failed"))
+	}
+	return NewTaskResult(t, TaskStatusBuilt, nil)
+}
+
+func (p *fakeProcess) processed(name string) int {
+	for i, n := range p.order {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestNewTaskGraph(t *testing.T) {
+	tests := []struct {
+		name  string
+		specs []string
+		err   string
+	}{
+		{"independent", []string{"a", "b"}, ""},
+		{"diamond", []string{"a", "b:a", "c:a", "d:b,c"}, ""},
+		{"cycle", []string{"a:c", "b:a", "c:b"}, "dependency cycle a -> b -> c -> a"},
+		{"self", []string{"a:a"}, "dependency cycle a -> a"},
+		{"cycle behind acyclic", []string{"a", "b:a,c", "c:b"}, "dependency cycle b -> c -> b"},
+		{"unselected", []string{"a:z"}, "package `a` depends on unselected package `z`"},
+	}
+
+	for _, test := range tests {
+		_, err := NewTaskGraph(graphTasks(test.specs...))
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.err != "" && (err == nil || err.Error() != test.err):
+			t.Errorf("%s: error %v want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestTaskGraphRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		specs    []string
+		fail     []string
+		parallel int
+		failFast bool
+		status   map[string]string
+		skipped  map[string]string // dependency failed of skipped task, empty for canceled
+	}{
+		{
+			name:     "all built",
+			specs:    []string{"a", "b:a", "c:a", "d:b,c"},
+			parallel: 3,
+			status:   map[string]string{"a": TaskStatusBuilt, "b": TaskStatusBuilt, "c": TaskStatusBuilt, "d": TaskStatusBuilt},
+		},
+		{
+			name:     "failure skip dependents transitively",
+			specs:    []string{"a", "b:a", "c:b", "d"},
+			fail:     []string{"a"},
+			parallel: 2,
+			status:   map[string]string{"a": TaskStatusFailed, "b": TaskStatusSkipped, "c": TaskStatusSkipped, "d": TaskStatusBuilt},
+			skipped:  map[string]string{"b": "a", "c": "b"},
+		},
+		{
+			name:     "failure skip diamond once",
+			specs:    []string{"a", "b:a", "c", "d:b,c"},
+			fail:     []string{"b"},
+			parallel: 2,
+			status:   map[string]string{"a": TaskStatusBuilt, "b": TaskStatusFailed, "c": TaskStatusBuilt, "d": TaskStatusSkipped},
+			skipped:  map[string]string{"d": "b"},
+		},
+		{
+			name:     "without fail fast queued task still built",
+			specs:    []string{"a", "b", "c:b"},
+			fail:     []string{"a"},
+			parallel: 1,
+			status:   map[string]string{"a": TaskStatusFailed, "b": TaskStatusBuilt, "c": TaskStatusBuilt},
+		},
+		{
+			// b already queued may run, c not dispatched when a failed
+			name:     "fail fast cancel not dispatched",
+			specs:    []string{"a", "b", "c:b"},
+			fail:     []string{"a"},
+			parallel: 1,
+			failFast: true,
+			status:   map[string]string{"a": TaskStatusFailed, "c": TaskStatusSkipped},
+			skipped:  map[string]string{"c": ""},
+		},
+	}
+
+	for _, test := range tests {
+		graph, err := NewTaskGraph(graphTasks(test.specs...))
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		fake := &fakeProcess{fail: make(map[string]bool)}
+		for _, name := range test.fail {
+			fake.fail[name] = true
+		}
+
+		results := graph.Run(test.parallel, test.failFast, fake.process)
+		if len(results) != len(graph.Tasks) {
+			t.Fatalf("%s: %d results of %d tasks", test.name, len(results), len(graph.Tasks))
+		}
+
+		for i, r := range results {
+			task := graph.Tasks[i]
+			if r.Name != task.Name {
+				t.Errorf("%s: result %d of %s want %s", test.name, i, r.Name, task.Name)
+			}
+			if status, ok := test.status[r.Name]; ok && r.Status != status {
+				t.Errorf("%s: %s status %s want %s", test.name, r.Name, r.Status, status)
+			}
+
+			if dependency, ok := test.skipped[r.Name]; ok {
+				var skipped *SkippedError
+				switch {
+				case dependency == "" && r.Err != ErrCanceled:
+					t.Errorf("%s: %s error %v want canceled", test.name, r.Name, r.Err)
+				case dependency != "" && (!errors.As(r.Err, &skipped) || skipped.Dependency != dependency):
+					t.Errorf("%s: %s error %v want dependency %s failed", test.name, r.Name, r.Err, dependency)
+				}
+			}
+
+			if r.Status == TaskStatusSkipped {
+				if fake.processed(r.Name) >= 0 {
+					t.Errorf("%s: skipped %s processed", test.name, r.Name)
+				}
+				continue
+			}
+			// dependency processed before its dependents
+			for _, dep := range task.Package.DependsOn {
+				if fake.processed(dep) > fake.processed(r.Name) {
+					t.Errorf("%s: %s processed before dependency %s", test.name, r.Name, dep)
+				}
+			}
+		}
+	}
+}
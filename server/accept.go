@@ -5,14 +5,70 @@ import (
 	"github.com/lucas-clemente/quic-go"
 	"gobuilder/log"
 	"gobuilder/quicpkg"
+	"io"
+	"sort"
+	"strconv"
 	"time"
 )
 
+func WritePackageNotFound(stream io.Writer, name string) error {
+	resp, err := quicpkg.NewErrorPacket(quicpkg.ErrorCodeNotFoundPackage,
+		"package `"+name+"` invalid")
+	if err != nil {
+		return err
+	}
+	return resp.WriteWithOp(stream)
+}
+
 func QUICConnectionIncoming(conn quic.Connection) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
-	defer cancel()
+	defer conn.CloseWithError(0, "")
+
+	peer := PeerFromConnection(conn)
+
+	// serve streams until client close connection or idle
+	for handled := 0; ; handled++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*15)
+		stream, err := conn.AcceptStream(ctx)
+		cancel()
+		if err != nil {
+			if handled > 0 {
+				return nil
+			}
+			return err
+		}
+
+		if err := QUICStreamIncoming(stream, peer); err != nil {
+			return err
+		}
+	}
+}
+
+var streamHandlers = map[quicpkg.Operation]func(stream io.ReadWriter, peer *Peer) error{
+	quicpkg.OperationPackageInfo:     HandlePackageInfoCommand,
+	quicpkg.OperationPackageGet:      HandlePackageGetCommand,
+	quicpkg.OperationPacketReplace:   HandlePackageReplaceCommand,
+	quicpkg.OperationPackageReleases: HandlePackageReleasesCommand,
+	quicpkg.OperationPackageRollback: HandlePackageRollbackCommand,
+}
+
+func SupportedOperations() []quicpkg.Operation {
+	ops := make([]quicpkg.Operation, 0, len(streamHandlers))
+	for op := range streamHandlers {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i] < ops[j]
+	})
+	return ops
+}
 
-	stream, err := conn.AcceptStream(ctx)
+func QUICStreamIncoming(stream quic.Stream, peer *Peer) error {
+	defer stream.Close()
+	return HandleStream(stream, peer)
+}
+
+func HandleStream(stream io.ReadWriter, peer *Peer) error {
+	handshake, err := quicpkg.ServerHandshake(stream, SupportedOperations())
 	if err != nil {
 		return err
 	}
@@ -23,17 +79,19 @@ func QUICConnectionIncoming(conn quic.Connection) error {
 	}
 	op := quicpkg.Operation(rawOp)
 
-	switch op {
-	case quicpkg.OperationPackageInfo:
-		err = HandlePackageInfoCommand(stream)
-	case quicpkg.OperationPackageGet:
-		err = HandlePackageGetCommand(stream)
-	case quicpkg.OperationPacketReplace:
-		err = HandlePackageReplaceCommand(stream)
+	handler, ok := streamHandlers[op]
+	if !ok {
+		log.Error("request", handshake.RequestID, "unknown operation", rawOp)
+		resp, err := quicpkg.NewErrorPacket(quicpkg.ErrorCodeUnknownOperation,
+			"unknown operation "+strconv.Itoa(int(rawOp)))
+		if err != nil {
+			return err
+		}
+		return resp.WriteWithOp(stream)
 	}
 
-	if err != nil {
-		log.Error("handle `"+op.String()+"` error", err)
+	if err := handler(stream, peer); err != nil {
+		log.Error("request", handshake.RequestID, peer.String(), "handle `"+op.String()+"` error", err)
 		resp, err := quicpkg.NewErrorPacket(quicpkg.ErrorCodeSystem, err.Error())
 		if err != nil {
 			return err
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"gobuilder/quicpkg"
+	"io"
+	"testing"
+)
+
+type readWriter struct {
+	io.Reader
+	io.Writer
+}
+
+func TestHandleStreamUnknownOperation(t *testing.T) {
+	in := bytes.NewBuffer(nil)
+	handshake, err := quicpkg.NewHandshake(3, []quicpkg.Operation{quicpkg.OperationPackageInfo})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := handshake.WriteWithMagic(in); err != nil {
+		t.Fatal(err)
+	}
+	in.WriteByte(0xEE)
+
+	out := bytes.NewBuffer(nil)
+	if err := HandleStream(readWriter{in, out}, &Peer{CommonName: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := []byte{
+		// handshake reply with supported operations
+		0x06, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05,
+		// unknown operation error
+		0x00, 0x05, 0x00, 0x15,
+	}
+	golden = append(golden, "unknown operation 238"...)
+
+	if !bytes.Equal(out.Bytes(), golden) {
+		t.Fatalf("reply\n got % x\nwant % x", out.Bytes(), golden)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/x509"
+	"github.com/lucas-clemente/quic-go"
+	"gobuilder/log"
+	"gobuilder/quicpkg"
+	"io"
+)
+
+const (
+	PermissionInfo    = "info"
+	PermissionGet     = "get"
+	PermissionReplace = "replace"
+)
+
+// PackageACL client identities by certificate CN or SAN each permission, `*` allow any client
+type PackageACL struct {
+	Info    []string `yaml:"info,omitempty"`
+	Get     []string `yaml:"get,omitempty"`
+	Replace []string `yaml:"replace,omitempty"`
+}
+
+func (a *PackageACL) identities(permission string) []string {
+	switch permission {
+	case PermissionInfo:
+		return a.Info
+	case PermissionGet:
+		return a.Get
+	case PermissionReplace:
+		return a.Replace
+	}
+	return nil
+}
+
+type Peer struct {
+	CommonName string
+	Names      []string // subject alternative names
+}
+
+func NewPeer(cert *x509.Certificate) *Peer {
+	if cert == nil {
+		return &Peer{}
+	}
+
+	peer := &Peer{CommonName: cert.Subject.CommonName}
+	peer.Names = append(peer.Names, cert.DNSNames...)
+	peer.Names = append(peer.Names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		peer.Names = append(peer.Names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		peer.Names = append(peer.Names, uri.String())
+	}
+	return peer
+}
+
+func PeerFromConnection(conn quic.Connection) *Peer {
+	certs := conn.ConnectionState().TLS.PeerCertificates
+	if len(certs) == 0 {
+		return NewPeer(nil)
+	}
+	return NewPeer(certs[0])
+}
+
+func (p *Peer) String() string {
+	if p.CommonName != "" {
+		return p.CommonName
+	}
+	if len(p.Names) > 0 {
+		return p.Names[0]
+	}
+	return "anonymous"
+}
+
+func (p *Peer) Match(identity string) bool {
+	if identity == "*" {
+		return true
+	}
+	if identity == "" {
+		return false
+	}
+	if p.CommonName == identity {
+		return true
+	}
+	for _, name := range p.Names {
+		if name == identity {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed package without acl allow every client signed by root
+func (p *Peer) Allowed(pkg *GoBuilderServerPackage, permission string) bool {
+	if pkg.ACL == nil {
+		return true
+	}
+	for _, identity := range pkg.ACL.identities(permission) {
+		if p.Match(identity) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize log decision and response permission denied, false mean request finished
+func Authorize(stream io.Writer, peer *Peer, name string, pkg *GoBuilderServerPackage, permission string) (bool, error) {
+	if peer.Allowed(pkg, permission) {
+		log.Log("allow", peer.String(), permission, "-", name)
+		return true, nil
+	}
+
+	log.Warn("deny", peer.String(), permission, "-", name)
+
+	resp, err := quicpkg.NewErrorPacket(quicpkg.ErrorCodePermissionDenied,
+		"client `"+peer.String()+"` not allowed `"+permission+"` package `"+name+"`")
+	if err != nil {
+		return false, err
+	}
+	return false, resp.WriteWithOp(stream)
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestPeerAllowed(t *testing.T) {
+	pkg := &GoBuilderServerPackage{ACL: &PackageACL{
+		Info:    []string{"*"},
+		Get:     []string{"ci.example.com"},
+		Replace: []string{"deployer"},
+	}}
+
+	tests := []struct {
+		peer       Peer
+		permission string
+		allowed    bool
+	}{
+		{Peer{CommonName: "anyone"}, PermissionInfo, true},
+		{Peer{CommonName: "ci", Names: []string{"ci.example.com"}}, PermissionGet, true},
+		{Peer{CommonName: "deployer"}, PermissionGet, false},
+		{Peer{CommonName: "deployer"}, PermissionReplace, true},
+		{Peer{CommonName: "ci", Names: []string{"ci.example.com"}}, PermissionReplace, false},
+		{Peer{}, PermissionReplace, false},
+	}
+
+	for _, test := range tests {
+		if allowed := test.peer.Allowed(pkg, test.permission); allowed != test.allowed {
+			t.Errorf("%s %s allowed %v want %v", test.peer.String(), test.permission, allowed, test.allowed)
+		}
+	}
+
+	if !(&Peer{CommonName: "anyone"}).Allowed(&GoBuilderServerPackage{}, PermissionReplace) {
+		t.Error("package without acl should allow any client")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gobuilder/log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type HealthCheck struct {
+	HTTP     string        `yaml:"http,omitempty"`    // GET expect 2xx status
+	TCP      string        `yaml:"tcp,omitempty"`     // host:port accept connection
+	Command  string        `yaml:"command,omitempty"` // exit zero
+	Timeout  time.Duration `yaml:"timeout,omitempty"` // each attempt, default 5s
+	Retries  int           `yaml:"retries,omitempty"` // default 3
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+func (h *HealthCheck) check(ctx context.Context, name string, pkg *GoBuilderServerPackage, signature []byte) (string, error) {
+	switch {
+	case h.HTTP != "":
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, h.HTTP, nil)
+		if err != nil {
+			return "", err
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return "", err
+		}
+		_ = response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return "", errors.New("http " + h.HTTP + " status " + response.Status)
+		}
+		return "http " + h.HTTP + " " + response.Status, nil
+	case h.TCP != "":
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", h.TCP)
+		if err != nil {
+			return "", err
+		}
+		_ = conn.Close()
+		return "tcp " + h.TCP + " connected", nil
+	case h.Command != "":
+		output, err := ActionCommand(ctx, h.Command, name, pkg, signature).CombinedOutput()
+		if err != nil {
+			return "", errors.New(err.Error() + "\n" + strings.TrimSpace(string(output)))
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+
+	return "", errors.New("health check without `http` `tcp` or `command`")
+}
+
+// Wait poll health check until passed or retries exhausted
+func (h *HealthCheck) Wait(name string, pkg *GoBuilderServerPackage, signature []byte) (string, error) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = time.Second * 5
+	}
+	retries := h.Retries
+	if retries <= 0 {
+		retries = 3
+	}
+	interval := h.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	var err error
+	for attempt := 1; attempt <= retries; attempt++ {
+		if attempt > 1 {
+			time.Sleep(interval)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		var message string
+		message, err = h.check(ctx, name, pkg, signature)
+		cancel()
+		if err == nil {
+			return message, nil
+		}
+
+		log.Warn("package", name, "health check attempt", strconv.Itoa(attempt)+"/"+strconv.Itoa(retries), "failed", err)
+	}
+
+	return "", errors.New("health check failed after " + strconv.Itoa(retries) + " attempts: " + err.Error())
+}
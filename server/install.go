@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var packageLocks sync.Map
+
+// LockPackage serialize install of same package, return unlock function
+func LockPackage(name string) func() {
+	m, _ := packageLocks.LoadOrStore(name, &sync.Mutex{})
+	mutex := m.(*sync.Mutex)
+	mutex.Lock()
+	return mutex.Unlock
+}
+
+func BackupPath(executable string) string {
+	return executable + ".bak"
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, stat.Mode())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// linkOrCopy hard link keep the old inode, copy when file system not support
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err != nil {
+		return copyFile(src, dst)
+	}
+	return nil
+}
+
+// InstallPackage move synced temp file over executable, the previous binary keep as backup
+func InstallPackage(tempPath, executable string) (bool, error) {
+	backup := BackupPath(executable)
+	hasBackup := false
+
+	if _, err := os.Stat(executable); err == nil {
+		if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		if err := linkOrCopy(executable, backup); err != nil {
+			return false, err
+		}
+		hasBackup = true
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	if err := os.Rename(tempPath, executable); err != nil {
+		return false, err
+	}
+
+	return hasBackup, syncDir(filepath.Dir(executable))
+}
+
+// RestorePackage move backup binary back to executable
+func RestorePackage(executable string) error {
+	if err := os.Rename(BackupPath(executable), executable); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(executable))
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"gobuilder/quicpkg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTemp(t *testing.T, dir, data string) string {
+	t.Helper()
+	f, err := os.CreateTemp(dir, ".app.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func expectContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("%s content %q want %q", filepath.Base(path), data, want)
+	}
+}
+
+func TestInstallPackage(t *testing.T) {
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "app")
+
+	hasBackup, err := InstallPackage(writeTemp(t, dir, "v1"), executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hasBackup {
+		t.Error("first install should not have backup")
+	}
+	expectContent(t, executable, "v1")
+
+	hasBackup, err = InstallPackage(writeTemp(t, dir, "v2"), executable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasBackup {
+		t.Error("second install should keep backup")
+	}
+	expectContent(t, executable, "v2")
+	expectContent(t, BackupPath(executable), "v1")
+
+	if err := RestorePackage(executable); err != nil {
+		t.Fatal(err)
+	}
+	expectContent(t, executable, "v1")
+	if _, err := os.Stat(BackupPath(executable)); !os.IsNotExist(err) {
+		t.Error("backup left after restore", err)
+	}
+	if err := RestorePackage(executable); err == nil {
+		t.Error("restore without backup should fail")
+	}
+}
+
+func TestDeployPackage(t *testing.T) {
+	failedCheck := &HealthCheck{Command: "false", Retries: 1, Interval: time.Millisecond}
+	passedCheck := &HealthCheck{Command: "true", Retries: 1}
+
+	tests := []struct {
+		name        string
+		afterAction string
+		healthCheck *HealthCheck
+		rollback    bool
+		health      quicpkg.HealthCheckStatus
+		want        string
+	}{
+		{"installed", "true", nil, false, quicpkg.HealthCheckNone, "v2"},
+		{"health check passed", "true", passedCheck, false, quicpkg.HealthCheckPassed, "v2"},
+		{"after action failed", "false", passedCheck, true, quicpkg.HealthCheckNone, "v1"},
+		{"health check failed", "true", failedCheck, true, quicpkg.HealthCheckFailed, "v1"},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		executable := filepath.Join(dir, "app")
+		if err := os.WriteFile(executable, []byte("v1"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		pkg := &GoBuilderServerPackage{Executable: executable, AfterAction: test.afterAction, HealthCheck: test.healthCheck}
+
+		response, err := DeployPackage("app", pkg, writeTemp(t, dir, "v2"), nil)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if response.Rollback != test.rollback || response.HealthCheck != test.health {
+			t.Errorf("%s: rollback %v health %d want %v %d", test.name, response.Rollback, response.HealthCheck, test.rollback, test.health)
+		}
+		expectContent(t, executable, test.want)
+	}
+
+	// nothing to restore on first install
+	dir := t.TempDir()
+	pkg := &GoBuilderServerPackage{Executable: filepath.Join(dir, "app"), AfterAction: "false"}
+	if _, err := DeployPackage("app", pkg, writeTemp(t, dir, "v1"), nil); err == nil || !strings.Contains(err.Error(), "without backup") {
+		t.Errorf("deploy without backup error %v", err)
+	}
+}
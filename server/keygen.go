@@ -1,80 +1,154 @@
 package main
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	cryptoRand "crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
+	"flag"
+	"gobuilder/log"
 	"math/big"
-	"math/rand"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
-func BasicCert(random *rand.Rand) (*x509.Certificate, *rsa.PrivateKey, error) {
-	privateKey, err := rsa.GenerateKey(cryptoRand.Reader, 2048)
+const (
+	KeyTypeRSA2048   = "rsa2048"
+	KeyTypeRSA3072   = "rsa3072"
+	KeyTypeRSA4096   = "rsa4096"
+	KeyTypeECDSAP256 = "ecdsa-p256"
+	KeyTypeEd25519   = "ed25519"
+)
+
+type CertOptions struct {
+	CommonName         string
+	SANs               []string
+	Days               int
+	KeyType            string
+	Country            string
+	Organization       string
+	OrganizationalUnit string
+}
+
+func GenerateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case "", KeyTypeRSA2048:
+		return rsa.GenerateKey(cryptoRand.Reader, 2048)
+	case KeyTypeRSA3072:
+		return rsa.GenerateKey(cryptoRand.Reader, 3072)
+	case KeyTypeRSA4096:
+		return rsa.GenerateKey(cryptoRand.Reader, 4096)
+	case KeyTypeECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), cryptoRand.Reader)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(cryptoRand.Reader)
+		return key, err
+	}
+	return nil, errors.New("invalid key type `" + keyType + "`")
+}
+
+func BasicCert(opts CertOptions) (*x509.Certificate, crypto.Signer, error) {
+	privateKey, err := GenerateKey(opts.KeyType)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	ca := &x509.Certificate{
-		SerialNumber: big.NewInt(random.Int63()),
+	serial, err := cryptoRand.Int(cryptoRand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	days := opts.Days
+	if days <= 0 {
+		days = 365 * 3
+	}
+
+	cert := &x509.Certificate{
+		SerialNumber: serial,
 		Subject: pkix.Name{
-			Country:            []string{"CN"},
-			Organization:       []string{"Anonymous"},
-			OrganizationalUnit: []string{"5L"},
+			CommonName: opts.CommonName,
 		},
 		NotBefore: time.Now(),
-		NotAfter:  time.Now().AddDate(3, 0, 0),
+		NotAfter:  time.Now().AddDate(0, 0, days),
+	}
+	if opts.Country != "" {
+		cert.Subject.Country = []string{opts.Country}
+	}
+	if opts.Organization != "" {
+		cert.Subject.Organization = []string{opts.Organization}
+	}
+	if opts.OrganizationalUnit != "" {
+		cert.Subject.OrganizationalUnit = []string{opts.OrganizationalUnit}
+	}
+
+	// SAN type detect by format
+	for _, san := range opts.SANs {
+		if ip := net.ParseIP(san); ip != nil {
+			cert.IPAddresses = append(cert.IPAddresses, ip)
+		} else if u, err := url.Parse(san); err == nil && u.Scheme != "" && u.Host != "" {
+			cert.URIs = append(cert.URIs, u)
+		} else if _, err := mail.ParseAddress(san); err == nil && strings.Contains(san, "@") {
+			cert.EmailAddresses = append(cert.EmailAddresses, san)
+		} else {
+			cert.DNSNames = append(cert.DNSNames, san)
+		}
 	}
 
-	return ca, privateKey, nil
+	return cert, privateKey, nil
 }
 
-func GenerateRootCert(random *rand.Rand) (*x509.Certificate, *rsa.PrivateKey, error) {
-	cert, key, err := BasicCert(random)
+func GenerateRootCert(opts CertOptions) (*x509.Certificate, crypto.Signer, error) {
+	cert, key, err := BasicCert(opts)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	cert.Subject.CommonName = "gobuilder-root"
 	cert.BasicConstraintsValid = true
 	cert.IsCA = true
-	cert.KeyUsage = x509.KeyUsageCertSign
+	cert.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 
 	return cert, key, nil
 }
 
-func GenerateServerCert(random *rand.Rand) (*x509.Certificate, *rsa.PrivateKey, error) {
-	cert, key, err := BasicCert(random)
+func GenerateServerCert(opts CertOptions) (*x509.Certificate, crypto.Signer, error) {
+	cert, key, err := BasicCert(opts)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	cert.Subject.CommonName = "gobuilder-server"
-	cert.DNSNames = []string{"gobuilder-quic"}
 	cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
-	cert.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	cert.KeyUsage = x509.KeyUsageDigitalSignature
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		cert.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
 
 	return cert, key, nil
 }
 
-func GenerateClientCert(random *rand.Rand) (*x509.Certificate, *rsa.PrivateKey, error) {
-	cert, key, err := BasicCert(random)
+func GenerateClientCert(opts CertOptions) (*x509.Certificate, crypto.Signer, error) {
+	cert, key, err := BasicCert(opts)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	cert.Subject.CommonName = "gobuilder-client"
 	cert.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
 	cert.KeyUsage = x509.KeyUsageDigitalSignature
 
 	return cert, key, nil
 }
 
-func SaveDataToPEM(data []byte, t, path string) error {
-	o, err := os.Create(path)
+func SaveDataToPEM(data []byte, t, path string, perm os.FileMode) error {
+	o, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		return err
 	}
@@ -86,50 +160,293 @@ func SaveDataToPEM(data []byte, t, path string) error {
 	})
 }
 
-func SaveCert(ca *x509.Certificate, cert *x509.Certificate, certPrivateKey *rsa.PrivateKey, privateKey *rsa.PrivateKey, name string) error {
-	der, err := x509.CreateCertificate(cryptoRand.Reader, cert, ca, &certPrivateKey.PublicKey, privateKey)
+func certExists(name string) bool {
+	for _, path := range []string{name + ".pem", name + ".key"} {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func SaveCert(ca *x509.Certificate, cert *x509.Certificate, certPrivateKey crypto.Signer, privateKey crypto.Signer, name string, force bool) error {
+	if !force && certExists(name) {
+		return errors.New("`" + name + "` already exists, use -force to overwrite")
+	}
+
+	der, err := x509.CreateCertificate(cryptoRand.Reader, cert, ca, certPrivateKey.Public(), privateKey)
 	if err != nil {
 		return err
 	}
 
-	if err := SaveDataToPEM(der, "CERTIFICATE", name+".pem"); err != nil {
+	keyDer, err := x509.MarshalPKCS8PrivateKey(certPrivateKey)
+	if err != nil {
 		return err
 	}
 
-	if err := SaveDataToPEM(x509.MarshalPKCS1PrivateKey(certPrivateKey),
-		"RSA PRIVATE KEY", name+".key"); err != nil {
+	if err := SaveDataToPEM(der, "CERTIFICATE", name+".pem", 0644); err != nil {
 		return err
 	}
 
+	if err := SaveDataToPEM(keyDer, "PRIVATE KEY", name+".key", 0600); err != nil {
+		return err
+	}
+
+	log.Ok("generate", name+".pem", name+".key", "serial", cert.SerialNumber.Text(16))
+
 	return nil
 }
 
-func GenerateCertAndKey() error {
-	random := rand.New(rand.NewSource(time.Now().UnixMicro()))
+func LoadCertificate(path string) (*x509.Certificate, error) {
+	certPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("`" + path + "` invalid certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func LoadPrivateKey(path string) (crypto.Signer, error) {
+	keyPEM, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("`" + path + "` invalid private key")
+	}
 
-	ca, caPrivateKey, err := GenerateRootCert(random)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("`" + path + "` unsupported private key")
+	}
+	return signer, nil
+}
+
+// backupCert keep previous cert & key with `.bak` suffix
+func backupCert(name string) error {
+	for _, path := range []string{name + ".pem", name + ".key"} {
+		if err := os.Rename(path, path+".bak"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeCert(name string) {
+	for _, path := range []string{name + ".pem", name + ".key"} {
+		_ = os.Remove(path)
+	}
+}
+
+type keygenFlags struct {
+	fs      *flag.FlagSet
+	opts    CertOptions
+	san     string
+	ca      string
+	out     string
+	force   bool
+	revoked string
+}
+
+func newKeygenFlags(name string) *keygenFlags {
+	f := &keygenFlags{fs: flag.NewFlagSet("keygen "+name, flag.ContinueOnError)}
+	f.fs.StringVar(&f.san, "san", "", "comma separated subject alternative names, dns ip email or uri")
+	f.fs.IntVar(&f.opts.Days, "days", 365*3, "validity days")
+	f.fs.StringVar(&f.opts.KeyType, "key", KeyTypeRSA2048, "key type rsa2048 rsa3072 rsa4096 ecdsa-p256 ed25519")
+	f.fs.StringVar(&f.opts.Country, "country", "CN", "subject country")
+	f.fs.StringVar(&f.opts.Organization, "org", "Anonymous", "subject organization")
+	f.fs.StringVar(&f.opts.OrganizationalUnit, "ou", "5L", "subject organizational unit")
+	f.fs.StringVar(&f.ca, "ca", "gobuilder-root", "root cert & key name")
+	f.fs.StringVar(&f.out, "out", "", "output cert & key name")
+	f.fs.BoolVar(&f.force, "force", false, "overwrite exists cert & key")
+	f.fs.StringVar(&f.revoked, "revoked", "gobuilder-revoked.yaml", "revoked certificate list")
+	return f
+}
+
+func (f *keygenFlags) parse(args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := f.fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if f.fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, f.fs.Arg(0))
+		args = f.fs.Args()[1:]
+	}
+
+	if f.san != "" {
+		for _, san := range strings.Split(f.san, ",") {
+			if san = strings.TrimSpace(san); san != "" {
+				f.opts.SANs = append(f.opts.SANs, san)
+			}
+		}
+	}
+
+	return positional, nil
+}
+
+func (f *keygenFlags) loadRoot() (*x509.Certificate, crypto.Signer, error) {
+	ca, err := LoadCertificate(f.ca + ".pem")
+	if err != nil {
+		return nil, nil, err
+	}
+	caKey, err := LoadPrivateKey(f.ca + ".key")
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca, caKey, nil
+}
+
+func (f *keygenFlags) output(name string) string {
+	if f.out != "" {
+		return f.out
+	}
+	return name
+}
+
+// GenerateCertAndKey create root, server and client cert
+func GenerateCertAndKey(f *keygenFlags) error {
+	for _, name := range []string{f.ca, "gobuilder-server", "gobuilder-client"} {
+		if !f.force && certExists(name) {
+			return errors.New("`" + name + "` already exists, use -force to overwrite")
+		}
+	}
+
+	rootOpts := f.opts
+	rootOpts.CommonName, rootOpts.SANs = "gobuilder-root", nil
+	ca, caPrivateKey, err := GenerateRootCert(rootOpts)
+	if err != nil {
+		return err
+	}
+	if err := SaveCert(ca, ca, caPrivateKey, caPrivateKey, f.ca, f.force); err != nil {
+		return err
+	}
+
+	serverOpts := f.opts
+	serverOpts.CommonName = "gobuilder-server"
+	if len(serverOpts.SANs) == 0 {
+		serverOpts.SANs = []string{"gobuilder-quic"}
+	}
+	server, serverPrivateKey, err := GenerateServerCert(serverOpts)
 	if err != nil {
 		return err
 	}
-	if err := SaveCert(ca, ca, caPrivateKey, caPrivateKey, "gobuilder-root"); err != nil {
+	if err := SaveCert(ca, server, serverPrivateKey, caPrivateKey, "gobuilder-server", f.force); err != nil {
 		return err
 	}
 
-	server, serverPrivateKey, err := GenerateServerCert(random)
+	clientOpts := f.opts
+	clientOpts.CommonName, clientOpts.SANs = "gobuilder-client", nil
+	client, clientPrivateKey, err := GenerateClientCert(clientOpts)
 	if err != nil {
 		return err
 	}
-	if err := SaveCert(ca, server, serverPrivateKey, caPrivateKey, "gobuilder-server"); err != nil {
+	return SaveCert(ca, client, clientPrivateKey, caPrivateKey, "gobuilder-client", f.force)
+}
+
+// IssueClientCert issue named client cert from exists root
+func IssueClientCert(f *keygenFlags, name string) error {
+	ca, caKey, err := f.loadRoot()
+	if err != nil {
+		return err
+	}
+
+	opts := f.opts
+	opts.CommonName = name
+	cert, key, err := GenerateClientCert(opts)
+	if err != nil {
 		return err
 	}
+	return SaveCert(ca, cert, key, caKey, f.output(name), f.force)
+}
 
-	client, clientPrivateKey, err := GenerateClientCert(random)
+// RotateServerCert replace server cert signed by exists root, previous cert keep as `.bak`
+func RotateServerCert(f *keygenFlags) error {
+	ca, caKey, err := f.loadRoot()
 	if err != nil {
 		return err
 	}
-	if err := SaveCert(ca, client, clientPrivateKey, caPrivateKey, "gobuilder-client"); err != nil {
+
+	opts := f.opts
+	opts.CommonName = "gobuilder-server"
+	if len(opts.SANs) == 0 {
+		opts.SANs = []string{"gobuilder-quic"}
+	}
+	cert, key, err := GenerateServerCert(opts)
+	if err != nil {
 		return err
 	}
 
+	// live pair untouched until new pair written
+	name := f.output("gobuilder-server")
+	if err := SaveCert(ca, cert, key, caKey, name+".new", true); err != nil {
+		removeCert(name + ".new")
+		return err
+	}
+	if err := backupCert(name); err != nil {
+		removeCert(name + ".new")
+		return err
+	}
+	for _, ext := range []string{".pem", ".key"} {
+		if err := os.Rename(name+".new"+ext, name+ext); err != nil {
+			return err
+		}
+	}
+
+	log.Ok("rotate", name+".pem", name+".key", "previous kept as .bak")
 	return nil
 }
+
+func KeygenCommand(args []string) error {
+	sub := "init"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		sub, args = args[0], args[1:]
+	}
+
+	f := newKeygenFlags(sub)
+	positional, err := f.parse(args)
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "init":
+		return GenerateCertAndKey(f)
+	case "client":
+		if len(positional) != 1 {
+			return errors.New("usage: gobuilder-server keygen client <name> [-san ...] [-days N] [-key type]")
+		}
+		return IssueClientCert(f, positional[0])
+	case "server":
+		return RotateServerCert(f)
+	case "revoke":
+		if len(positional) == 0 {
+			return errors.New("usage: gobuilder-server keygen revoke <cert.pem>... [-revoked path]")
+		}
+		for _, path := range positional {
+			if err := RevokeCert(f.revoked, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return errors.New("unknown keygen command `" + sub + "`, expect init client server revoke")
+}
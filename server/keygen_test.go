@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateKey(t *testing.T) {
+	tests := []struct {
+		keyType string
+		check   func(key interface{}) bool
+	}{
+		{"", func(key interface{}) bool { k, ok := key.(*rsa.PrivateKey); return ok && k.N.BitLen() == 2048 }},
+		{KeyTypeRSA2048, func(key interface{}) bool { k, ok := key.(*rsa.PrivateKey); return ok && k.N.BitLen() == 2048 }},
+		{KeyTypeECDSAP256, func(key interface{}) bool { _, ok := key.(*ecdsa.PrivateKey); return ok }},
+		{KeyTypeEd25519, func(key interface{}) bool { _, ok := key.(ed25519.PrivateKey); return ok }},
+	}
+
+	for _, test := range tests {
+		key, err := GenerateKey(test.keyType)
+		if err != nil {
+			t.Errorf("%q %v", test.keyType, err)
+			continue
+		}
+		if !test.check(key) {
+			t.Errorf("%q unexpected key %T", test.keyType, key)
+		}
+	}
+
+	if _, err := GenerateKey("dsa"); err == nil {
+		t.Error("invalid key type should fail")
+	}
+}
+
+func TestBasicCertSANs(t *testing.T) {
+	cert, _, err := BasicCert(CertOptions{
+		CommonName: "test",
+		KeyType:    KeyTypeECDSAP256,
+		SANs: []string{"gobuilder-quic", "10.0.0.1", "::1", "build.example.com",
+			"spiffe://example.com/deployer", "ops@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ips, uris []string
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	for _, u := range cert.URIs {
+		uris = append(uris, u.String())
+	}
+
+	tests := []struct {
+		kind string
+		got  []string
+		want []string
+	}{
+		{"dns", cert.DNSNames, []string{"gobuilder-quic", "build.example.com"}},
+		{"ip", ips, []string{"10.0.0.1", "::1"}},
+		{"uri", uris, []string{"spiffe://example.com/deployer"}},
+		{"email", cert.EmailAddresses, []string{"ops@example.com"}},
+	}
+
+	for _, test := range tests {
+		if len(test.got) != len(test.want) {
+			t.Errorf("%s %v want %v", test.kind, test.got, test.want)
+			continue
+		}
+		for i := range test.want {
+			if test.got[i] != test.want[i] {
+				t.Errorf("%s %v want %v", test.kind, test.got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func issueTestCert(t *testing.T, dir, name string) *x509.Certificate {
+	t.Helper()
+	ca, caKey, err := GenerateRootCert(CertOptions{CommonName: "root", KeyType: KeyTypeECDSAP256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, key, err := GenerateClientCert(CertOptions{CommonName: name, KeyType: KeyTypeECDSAP256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveCert(ca, cert, key, caKey, filepath.Join(dir, name), false); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCertificate(filepath.Join(dir, name+".pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func TestRevokeCert(t *testing.T) {
+	dir := t.TempDir()
+	revokedPath := filepath.Join(dir, "revoked.yaml")
+	revokedCert := issueTestCert(t, dir, "leaked")
+	validCert := issueTestCert(t, dir, "deployer")
+
+	// revoke twice keep single entry
+	for i := 0; i < 2; i++ {
+		if err := RevokeCert(revokedPath, filepath.Join(dir, "leaked.pem")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revoked, err := ReadRevoked(revokedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revoked) != 1 || revoked[0].Serial != revokedCert.SerialNumber.Text(16) || revoked[0].CommonName != "leaked" {
+		t.Fatalf("revoked %+v", revoked)
+	}
+
+	if err := LoadRevoked(revokedPath); err != nil {
+		t.Fatal(err)
+	}
+	defer LoadRevoked("")
+
+	tests := []struct {
+		chain   []*x509.Certificate
+		allowed bool
+	}{
+		{[]*x509.Certificate{validCert}, true},
+		{[]*x509.Certificate{revokedCert}, false},
+		{[]*x509.Certificate{validCert, revokedCert}, false},
+	}
+	for _, test := range tests {
+		err := VerifyNotRevoked(nil, [][]*x509.Certificate{test.chain})
+		if (err == nil) != test.allowed {
+			t.Errorf("chain %s allowed %v want %v", test.chain[0].Subject.CommonName, err == nil, test.allowed)
+		}
+	}
+
+	if err := LoadRevoked(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyNotRevoked(nil, [][]*x509.Certificate{{revokedCert}}); err != nil {
+		t.Error("empty revoked path should disable revocation", err)
+	}
+}
+
+func TestRotateServerCert(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	server := filepath.Join(dir, "server")
+
+	ca, caKey, err := GenerateRootCert(CertOptions{CommonName: "root", KeyType: KeyTypeECDSAP256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveCert(ca, ca, caKey, caKey, root, false); err != nil {
+		t.Fatal(err)
+	}
+
+	f := newKeygenFlags("server")
+	if _, err := f.parse([]string{"-ca", root, "-out", server, "-key", KeyTypeECDSAP256}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RotateServerCert(f); err != nil {
+		t.Fatal(err)
+	}
+	first, err := LoadCertificate(server + ".pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RotateServerCert(f); err != nil {
+		t.Fatal(err)
+	}
+	second, err := LoadCertificate(server + ".pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := LoadCertificate(server + ".pem.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.SerialNumber.Cmp(first.SerialNumber) == 0 || backup.SerialNumber.Cmp(first.SerialNumber) != 0 {
+		t.Fatalf("rotated %s backup %s first %s", second.SerialNumber, backup.SerialNumber, first.SerialNumber)
+	}
+	if _, err := os.Stat(server + ".new.pem"); !os.IsNotExist(err) {
+		t.Error("temp cert left after rotate", err)
+	}
+
+	// new pair not writable, live pair and backup untouched
+	if err := os.Mkdir(server+".new.key", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := RotateServerCert(f); err == nil {
+		t.Fatal("rotate with unwritable key should fail")
+	}
+	if live, err := LoadCertificate(server + ".pem"); err != nil || live.SerialNumber.Cmp(second.SerialNumber) != 0 {
+		t.Error("live cert changed by failed rotate", err)
+	}
+	if _, err := LoadPrivateKey(server + ".key"); err != nil {
+		t.Error("live key lost by failed rotate", err)
+	}
+	if backup, err := LoadCertificate(server + ".pem.bak"); err != nil || backup.SerialNumber.Cmp(first.SerialNumber) != 0 {
+		t.Error("backup changed by failed rotate", err)
+	}
+}
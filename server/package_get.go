@@ -1,12 +1,12 @@
 package main
 
 import (
-	"bytes"
-	"github.com/lucas-clemente/quic-go"
 	"gobuilder/quicpkg"
+	"io"
+	"os"
 )
 
-func HandlePackageGetCommand(stream quic.Stream) error {
+func HandlePackageGetCommand(stream io.ReadWriter, peer *Peer) error {
 	request := quicpkg.PacketPackageName{}
 	if err := request.Read(stream); err != nil {
 		return err
@@ -14,31 +14,37 @@ func HandlePackageGetCommand(stream quic.Stream) error {
 
 	pkg, ok := ServerConfig.Packages[request.Package.Data]
 	if !ok {
-		resp, err := quicpkg.NewErrorPacket(quicpkg.ErrorCodeNotFoundPackage,
-			"package `"+request.Package.Data+"` invalid")
-		if err != nil {
-			return err
-		}
-		if err := resp.WriteWithOp(stream); err != nil {
-			return err
-		}
-		return nil
+		return WritePackageNotFound(stream, request.Package.Data)
 	}
 
-	buf := bytes.NewBuffer([]byte{})
+	if ok, err := Authorize(stream, peer, request.Package.Data, pkg, PermissionGet); !ok {
+		return err
+	}
+
+	// keep binary unchanged between hash and send
+	unlock := LockPackage(request.Package.Data)
+	defer unlock()
+
+	infoPackage, err := GetPackageInformation(pkg.Executable, nil)
+	if err != nil {
+		return err
+	}
 
-	infoPackage, err := GetPackageInformation(pkg.Executable, buf)
+	o, err := os.Open(pkg.Executable)
 	if err != nil {
 		return err
 	}
+	defer o.Close()
 
-	responsePacket := quicpkg.PacketPackage{
+	responsePacket := quicpkg.PacketPackageHeader{
 		Signature: infoPackage.Signature,
-		Data: quicpkg.Data[uint64, []byte]{
-			Size: infoPackage.BinarySize,
-			Data: buf.Bytes(),
-		},
+		Size:      infoPackage.BinarySize,
+	}
+
+	if err := responsePacket.WriteWithOp(stream); err != nil {
+		return err
 	}
 
-	return responsePacket.WriteWithOp(stream)
+	_, err = quicpkg.WriteChunks(stream, o)
+	return err
 }
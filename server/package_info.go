@@ -2,7 +2,6 @@ package main
 
 import (
 	"crypto/sha256"
-	"github.com/lucas-clemente/quic-go"
 	"gobuilder/quicpkg"
 	"io"
 	"os"
@@ -15,14 +14,11 @@ func GetPackageInformation(executable string, w io.Writer) (*quicpkg.PacketPacka
 	}
 	defer o.Close()
 
-	length, err := o.Seek(io.SeekEnd, 0)
+	stat, err := o.Stat()
 	if err != nil {
 		return nil, err
 	}
-
-	if _, err := o.Seek(io.SeekStart, 0); err != nil {
-		return nil, err
-	}
+	length := stat.Size()
 
 	hashChunk := make([]byte, 1024)
 	hashFunc := sha256.New()
@@ -55,7 +51,7 @@ func GetPackageInformation(executable string, w io.Writer) (*quicpkg.PacketPacka
 	return &response, nil
 }
 
-func HandlePackageInfoCommand(stream quic.Stream) error {
+func HandlePackageInfoCommand(stream io.ReadWriter, peer *Peer) error {
 	request := quicpkg.PacketPackageName{}
 	if err := request.Read(stream); err != nil {
 		return err
@@ -63,18 +59,18 @@ func HandlePackageInfoCommand(stream quic.Stream) error {
 
 	pkg, ok := ServerConfig.Packages[request.Package.Data]
 	if !ok {
-		resp, err := quicpkg.NewErrorPacket(quicpkg.ErrorCodeNotFoundPackage,
-			"package `"+request.Package.Data+"` invalid")
-		if err != nil {
-			return err
-		}
-		if err := resp.WriteWithOp(stream); err != nil {
-			return err
-		}
-		return nil
+		return WritePackageNotFound(stream, request.Package.Data)
 	}
 
+	if ok, err := Authorize(stream, peer, request.Package.Data, pkg, PermissionInfo); !ok {
+		return err
+	}
+
+	// not deployed yet response empty information
 	response, err := GetPackageInformation(pkg.Executable, nil)
+	if os.IsNotExist(err) {
+		response, err = &quicpkg.PacketPackageInfo{}, nil
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/hex"
+	"gobuilder/quicpkg"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+func HandlePackageReleasesCommand(stream io.ReadWriter, peer *Peer) error {
+	request := quicpkg.PacketPackageName{}
+	if err := request.Read(stream); err != nil {
+		return err
+	}
+
+	pkg, ok := ServerConfig.Packages[request.Package.Data]
+	if !ok {
+		return WritePackageNotFound(stream, request.Package.Data)
+	}
+
+	if ok, err := Authorize(stream, peer, request.Package.Data, pkg, PermissionInfo); !ok {
+		return err
+	}
+
+	releases, err := ListReleases(request.Package.Data)
+	if err != nil {
+		return err
+	}
+
+	response := quicpkg.PacketPackageReleases{}
+
+	info, err := GetPackageInformation(pkg.Executable, nil)
+	if err == nil {
+		response.Current = info.Signature
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, release := range releases {
+		signature, err := hex.DecodeString(release.Signature)
+		if err != nil {
+			return err
+		}
+		version, err := quicpkg.NewString[uint8](release.Version)
+		if err != nil {
+			return err
+		}
+		gitHash, err := quicpkg.NewString[uint8](release.GitHash)
+		if err != nil {
+			return err
+		}
+		sum, err := quicpkg.NewBytes[uint8](signature)
+		if err != nil {
+			return err
+		}
+		response.Releases = append(response.Releases, quicpkg.PacketRelease{
+			Version:   version,
+			GitHash:   gitHash,
+			Timestamp: release.Timestamp.Unix(),
+			Signature: sum,
+		})
+	}
+
+	return response.WriteWithOp(stream)
+}
+
+func HandlePackageRollbackCommand(stream io.ReadWriter, peer *Peer) error {
+	request := quicpkg.PacketPackageRollback{}
+	if err := request.Read(stream); err != nil {
+		return err
+	}
+
+	pkg, ok := ServerConfig.Packages[request.Package.Data]
+	if !ok {
+		return WritePackageNotFound(stream, request.Package.Data)
+	}
+
+	if ok, err := Authorize(stream, peer, request.Package.Data, pkg, PermissionReplace); !ok {
+		return err
+	}
+
+	unlock := LockPackage(request.Package.Data)
+	defer unlock()
+
+	releases, err := ListReleases(request.Package.Data)
+	if err != nil {
+		return err
+	}
+
+	var current []byte
+	if info, err := GetPackageInformation(pkg.Executable, nil); err == nil {
+		current = info.Signature.Data
+	}
+
+	release, err := FindRelease(releases, request.Version.Data, current)
+	if err != nil {
+		resp, err := quicpkg.NewErrorPacket(quicpkg.ErrorCodeNotFoundRelease, err.Error())
+		if err != nil {
+			return err
+		}
+		return resp.WriteWithOp(stream)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(pkg.Executable), "."+filepath.Base(pkg.Executable)+".*")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	defer os.Remove(tempPath)
+
+	if err := copyFile(release.Binary(), tempPath); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, pkg.FilePerm()); err != nil {
+		return err
+	}
+
+	signature, err := hex.DecodeString(release.Signature)
+	if err != nil {
+		return err
+	}
+
+	response, err := DeployPackage(request.Package.Data, pkg, tempPath, signature)
+	if err != nil {
+		return err
+	}
+
+	return response.WriteWithOp(stream)
+}
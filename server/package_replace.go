@@ -1,29 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"github.com/lucas-clemente/quic-go"
+	"gobuilder/log"
 	"gobuilder/quicpkg"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 )
 
-func ExecAction(action string, name string, config *GoBuilderServerPackage, request quicpkg.PacketPackageReplace) ([]byte, error) {
-	if action == "" {
-		return nil, nil
-	}
-
+func ActionCommand(ctx context.Context, action string, name string, config *GoBuilderServerPackage, signature []byte) *exec.Cmd {
 	args := strings.Split(action, " ")
-	if len(args) == 0 {
-		return nil, nil
-	}
 
-	command := exec.Command(args[0], args[1:]...)
+	command := exec.CommandContext(ctx, args[0], args[1:]...)
 	command.Env = append(os.Environ(),
 		"PACKAGE_NAME="+name,
-		"PACKAGE_HASH="+hex.EncodeToString(request.Signature.Data),
+		"PACKAGE_HASH="+hex.EncodeToString(signature),
 		"PACKAGE_PATH="+config.Executable,
 	)
 
@@ -31,70 +29,144 @@ func ExecAction(action string, name string, config *GoBuilderServerPackage, requ
 		command.Env = append(command.Env, k+"="+v)
 	}
 
-	return command.CombinedOutput()
+	return command
 }
 
-func HandlePackageReplaceCommand(stream quic.Stream) error {
-	request := quicpkg.PacketPackageReplace{}
-	if err := request.Read(stream); err != nil {
-		return err
+func ExecAction(action string, name string, config *GoBuilderServerPackage, signature []byte) ([]byte, error) {
+	if action == "" {
+		return nil, nil
 	}
 
-	pkg, ok := ServerConfig.Packages[request.Package.Data]
-	if !ok {
-		resp, err := quicpkg.NewErrorPacket(quicpkg.ErrorCodeNotFoundPackage,
-			"package `"+request.Package.Data+"` invalid")
-		if err != nil {
-			return err
-		}
-		if err := resp.WriteWithOp(stream); err != nil {
-			return err
-		}
-		return nil
+	return ActionCommand(context.Background(), action, name, config, signature).CombinedOutput()
+}
+
+func ReceivePackage(stream io.Reader, executable string, perm os.FileMode, header quicpkg.PacketPackageHeader) (string, error) {
+	o, err := os.CreateTemp(filepath.Dir(executable), "."+filepath.Base(executable)+".*")
+	if err != nil {
+		return "", err
 	}
 
+	hashFunc := sha256.New()
+	n, err := quicpkg.ReadChunks(stream, io.MultiWriter(o, hashFunc), header.Size)
+	if err == nil && n != header.Size {
+		err = errors.New("data corrupt")
+	}
+	if err == nil && !bytes.Equal(hashFunc.Sum(nil), header.Signature.Data) {
+		err = errors.New("signature mismatch")
+	}
+	if err == nil {
+		err = o.Chmod(perm)
+	}
+	if err == nil {
+		err = o.Sync()
+	}
+	if closeErr := o.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(o.Name())
+		return "", err
+	}
+
+	return o.Name(), nil
+}
+
+// DeployPackage run actions around install temp file, restore previous binary when after action or health check failed
+func DeployPackage(name string, pkg *GoBuilderServerPackage, tempPath string, signature []byte) (*quicpkg.PacketPackageReplaceResponse, error) {
 	// running command
-	beforeStdout, err := ExecAction(pkg.BeforeAction, request.Package.Data, pkg, request)
+	beforeStdout, err := ExecAction(pkg.BeforeAction, name, pkg, signature)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	hasBackup, err := InstallPackage(tempPath, pkg.Executable)
+	if err != nil {
+		return nil, err
 	}
 
-	filePerm := os.FileMode(0755)
-	if pkg.Perm > 0 {
-		filePerm = pkg.Perm
+	response := &quicpkg.PacketPackageReplaceResponse{
+		BeforeStdout: quicpkg.TruncateString[uint32](string(beforeStdout)),
 	}
 
-	o, err := os.OpenFile(pkg.Executable, os.O_RDWR|os.O_CREATE|os.O_TRUNC, filePerm)
+	afterStdout, err := ExecAction(pkg.AfterAction, name, pkg, signature)
+	response.AfterStdout = quicpkg.TruncateString[uint32](string(afterStdout))
+	if err == nil && pkg.HealthCheck != nil {
+		var message string
+		message, err = pkg.HealthCheck.Wait(name, pkg, signature)
+		response.HealthCheck = quicpkg.HealthCheckPassed
+		if err != nil {
+			response.HealthCheck = quicpkg.HealthCheckFailed
+			message = err.Error()
+		}
+		// health check output unbounded, message only informative
+		response.HealthCheckMessage = quicpkg.TruncateString[uint16](message)
+	}
 	if err != nil {
+		if !hasBackup {
+			return nil, errors.New("deploy failed without backup to restore " + err.Error())
+		}
+
+		log.Warn("package", name, "deploy failed, restore backup", err)
+
+		if err := RestorePackage(pkg.Executable); err != nil {
+			return nil, err
+		}
+
+		// restart previous binary
+		info, err := GetPackageInformation(pkg.Executable, nil)
+		if err != nil {
+			return nil, err
+		}
+		rollbackStdout, err := ExecAction(pkg.AfterAction, name, pkg, info.Signature.Data)
+		if err != nil {
+			log.Error("package", name, "after action failed on restored binary", err)
+		}
+
+		response.Rollback = true
+		response.RollbackStdout = quicpkg.TruncateString[uint32](string(rollbackStdout))
+	}
+
+	return response, nil
+}
+
+func HandlePackageReplaceCommand(stream io.ReadWriter, peer *Peer) error {
+	request := quicpkg.PacketPackageReplace{}
+	if err := request.Read(stream); err != nil {
 		return err
 	}
 
-	n, err := o.Write(request.Data.Data)
-	if err != nil {
+	pkg, ok := ServerConfig.Packages[request.Package.Data]
+	if !ok {
+		return WritePackageNotFound(stream, request.Package.Data)
+	}
+
+	if ok, err := Authorize(stream, peer, request.Package.Data, pkg, PermissionReplace); !ok {
 		return err
 	}
-	if uint64(n) != request.Data.Size {
-		return errors.New("data corrupt")
+
+	if err := quicpkg.WriteReplaceAccept(stream); err != nil {
+		return err
 	}
 
-	if err := o.Close(); err != nil {
+	tempPath, err := ReceivePackage(stream, pkg.Executable, pkg.FilePerm(), request.PacketPackageHeader)
+	if err != nil {
 		return err
 	}
+	defer os.Remove(tempPath)
+
+	unlock := LockPackage(request.Package.Data)
+	defer unlock()
 
-	afterStdout, err := ExecAction(pkg.AfterAction, request.Package.Data, pkg, request)
+	response, err := DeployPackage(request.Package.Data, pkg, tempPath, request.Signature.Data)
 	if err != nil {
 		return err
 	}
 
-	response := quicpkg.PacketPackageReplaceResponse{
-		BeforeStdout: quicpkg.Data[uint32, string]{
-			Size: uint32(len(beforeStdout)),
-			Data: string(beforeStdout),
-		},
-		AfterStdout: quicpkg.Data[uint32, string]{
-			Size: uint32(len(afterStdout)),
-			Data: string(afterStdout),
-		},
+	if !response.Rollback {
+		if err := ArchiveRelease(request.Package.Data, pkg,
+			request.Version.Data, request.GitHash.Data, request.Signature.Data); err != nil {
+			log.Error("package", request.Package.Data, "archive release failed", err)
+		}
 	}
 
 	return response.WriteWithOp(stream)
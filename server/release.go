@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	releaseBinary   = "binary"
+	releaseMetadata = "release.yaml"
+)
+
+type Release struct {
+	Version   string    `yaml:"version"`
+	GitHash   string    `yaml:"git-hash"`
+	Timestamp time.Time `yaml:"timestamp"`
+	Signature string    `yaml:"signature"` // hex sha256
+	Dir       string    `yaml:"-"`
+}
+
+func (r Release) Binary() string {
+	return filepath.Join(r.Dir, releaseBinary)
+}
+
+func ReleaseHistoryDir(name string) string {
+	if ServerConfig.ReleaseDir == "" {
+		return ""
+	}
+	return filepath.Join(ServerConfig.ReleaseDir, name)
+}
+
+func KeepReleases(pkg *GoBuilderServerPackage) int {
+	if pkg.KeepReleases > 0 {
+		return pkg.KeepReleases
+	}
+	if ServerConfig.KeepReleases > 0 {
+		return ServerConfig.KeepReleases
+	}
+	return 5
+}
+
+func releaseDirName(t time.Time, version string) string {
+	version = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, version)
+	if version == "" || version == "." || version == ".." {
+		version = "unknown"
+	}
+	return t.UTC().Format("20060102T150405.000000000Z") + "-" + version
+}
+
+// ArchiveRelease copy current executable into release history then remove oldest releases
+func ArchiveRelease(name string, pkg *GoBuilderServerPackage, version, gitHash string, signature []byte) error {
+	historyDir := ReleaseHistoryDir(name)
+	if historyDir == "" {
+		return nil
+	}
+
+	now := time.Now()
+	dir := filepath.Join(historyDir, releaseDirName(now, version))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	release := Release{
+		Version:   version,
+		GitHash:   gitHash,
+		Timestamp: now,
+		Signature: hex.EncodeToString(signature),
+		Dir:       dir,
+	}
+
+	if err := linkOrCopy(pkg.Executable, release.Binary()); err != nil {
+		return err
+	}
+
+	metadata, err := yaml.Marshal(release)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, releaseMetadata), metadata, 0644); err != nil {
+		return err
+	}
+
+	return PruneReleases(name, KeepReleases(pkg))
+}
+
+// ListReleases newest release first
+func ListReleases(name string) ([]Release, error) {
+	historyDir := ReleaseHistoryDir(name)
+	if historyDir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(historyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var releases []Release
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(historyDir, entry.Name())
+		metadata, err := os.ReadFile(filepath.Join(dir, releaseMetadata))
+		if err != nil {
+			continue
+		}
+		var release Release
+		if err := yaml.Unmarshal(metadata, &release); err != nil {
+			continue
+		}
+		release.Dir = dir
+		releases = append(releases, release)
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Dir > releases[j].Dir
+	})
+
+	return releases, nil
+}
+
+func PruneReleases(name string, keep int) error {
+	releases, err := ListReleases(name)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(releases); i++ {
+		if err := os.RemoveAll(releases[i].Dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindRelease empty version select the release before current binary
+func FindRelease(releases []Release, version string, current []byte) (*Release, error) {
+	if version != "" {
+		for i := range releases {
+			if releases[i].Version == version {
+				return &releases[i], nil
+			}
+		}
+		return nil, errors.New("release `" + version + "` not found")
+	}
+
+	currentHash := hex.EncodeToString(current)
+	for i := range releases {
+		if releases[i].Signature != currentHash {
+			continue
+		}
+		for j := i + 1; j < len(releases); j++ {
+			if releases[j].Signature != currentHash {
+				return &releases[j], nil
+			}
+		}
+		return nil, errors.New("no previous release")
+	}
+
+	if len(releases) == 0 {
+		return nil, errors.New("no previous release")
+	}
+	return &releases[0], nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestFindRelease(t *testing.T) {
+	current := []byte{0xc3}
+	currentHash := hex.EncodeToString(current)
+
+	// newest first
+	releases := []Release{
+		{Version: "1.0.3", Signature: currentHash},
+		{Version: "1.0.3-retry", Signature: currentHash},
+		{Version: "1.0.2", Signature: "b2"},
+		{Version: "1.0.1", Signature: "a1"},
+	}
+
+	tests := []struct {
+		name     string
+		releases []Release
+		version  string
+		current  []byte
+		want     string
+		err      string
+	}{
+		{"version", releases, "1.0.1", current, "1.0.1", ""},
+		{"version not found", releases, "0.9.0", current, "", "release `0.9.0` not found"},
+		{"previous of current skip same binary", releases, "", current, "1.0.2", ""},
+		{"current is oldest", releases[:2], "", current, "", "no previous release"},
+		{"current not in history", releases, "", []byte{0xff}, "1.0.3", ""},
+		{"empty history", nil, "", current, "", "no previous release"},
+	}
+
+	for _, test := range tests {
+		release, err := FindRelease(test.releases, test.version, test.current)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: error %v want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if release.Version != test.want {
+			t.Errorf("%s: release %s want %s", test.name, release.Version, test.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"gobuilder/log"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sync"
+	"time"
+)
+
+type RevokedCert struct {
+	Serial     string    `yaml:"serial"` // hex serial number
+	CommonName string    `yaml:"common-name,omitempty"`
+	RevokedAt  time.Time `yaml:"revoked-at"`
+}
+
+var (
+	revokedLock    sync.RWMutex
+	revokedSerials map[string]bool
+)
+
+func ReadRevoked(path string) ([]RevokedCert, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var revoked []RevokedCert
+	if err := yaml.Unmarshal(data, &revoked); err != nil {
+		return nil, err
+	}
+	return revoked, nil
+}
+
+// RevokeCert append certificate serial to revoked list
+func RevokeCert(revokedPath, certPath string) error {
+	cert, err := LoadCertificate(certPath)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := ReadRevoked(revokedPath)
+	if err != nil {
+		return err
+	}
+
+	serial := cert.SerialNumber.Text(16)
+	for _, r := range revoked {
+		if r.Serial == serial {
+			log.Warn("already revoked", certPath, "serial", serial)
+			return nil
+		}
+	}
+
+	revoked = append(revoked, RevokedCert{
+		Serial:     serial,
+		CommonName: cert.Subject.CommonName,
+		RevokedAt:  time.Now(),
+	})
+
+	data, err := yaml.Marshal(revoked)
+	if err != nil {
+		return err
+	}
+
+	temp := revokedPath + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, revokedPath); err != nil {
+		return err
+	}
+
+	log.Ok("revoke", certPath, cert.Subject.CommonName, "serial", serial)
+
+	return nil
+}
+
+// LoadRevoked replace enforced revoked serials, empty path disable
+func LoadRevoked(path string) error {
+	serials := make(map[string]bool)
+	if path != "" {
+		revoked, err := ReadRevoked(path)
+		if err != nil {
+			return err
+		}
+		for _, r := range revoked {
+			serials[r.Serial] = true
+		}
+	}
+
+	revokedLock.Lock()
+	revokedSerials = serials
+	revokedLock.Unlock()
+
+	return nil
+}
+
+func VerifyNotRevoked(_ [][]byte, chains [][]*x509.Certificate) error {
+	revokedLock.RLock()
+	defer revokedLock.RUnlock()
+
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if revokedSerials[cert.SerialNumber.Text(16)] {
+				return errors.New("certificate `" + cert.Subject.CommonName + "` revoked")
+			}
+		}
+	}
+	return nil
+}
@@ -48,7 +48,7 @@ func main() {
 	if len(commands) > 0 {
 		switch commands[0] {
 		case "keygen":
-			if err := GenerateCertAndKey(); err != nil {
+			if err := KeygenCommand(commands[1:]); err != nil {
 				log.Error("generate failed", err)
 			}
 			return
@@ -69,6 +69,11 @@ func main() {
 		ServerConfig.Address = ":2030"
 	}
 
+	if err := LoadRevoked(ServerConfig.Revoked); err != nil {
+		log.Error("read revoked list failed", err)
+		return
+	}
+
 	tlsCert, err := ServerConfig.GetTlsCert()
 	if err != nil {
 		log.Error("read tls cert failed", err)
@@ -90,6 +95,8 @@ func main() {
 		NextProtos:   []string{"gobuilder-quic"},
 		ServerName:   "gobuilder-quic",
 		ClientAuth:   tls.RequireAndVerifyClientCert,
+		// chains already verified by ClientCAs
+		VerifyPeerCertificate: VerifyNotRevoked,
 	}
 
 	quicConfig := &quic.Config{
@@ -151,6 +158,10 @@ func main() {
 						log.Error("read config file failed", err)
 					} else {
 						ServerConfig.Packages = config.Packages
+						ServerConfig.Revoked = config.Revoked
+						if err := LoadRevoked(ServerConfig.Revoked); err != nil {
+							log.Error("read revoked list failed", err)
+						}
 						log.Ok("reload config file")
 					}
 				case syscall.SIGINT:
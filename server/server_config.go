@@ -12,6 +12,16 @@ type GoBuilderServerPackage struct {
 	Perm         os.FileMode       `yaml:"perm,omitempty"`
 	Executable   string            `yaml:"executable"`
 	AfterAction  string            `yaml:"after-action"`
+	KeepReleases int               `yaml:"keep-releases,omitempty"`
+	HealthCheck  *HealthCheck      `yaml:"health-check,omitempty"`
+	ACL          *PackageACL       `yaml:"acl,omitempty"`
+}
+
+func (p *GoBuilderServerPackage) FilePerm() os.FileMode {
+	if p.Perm > 0 {
+		return p.Perm
+	}
+	return 0755
 }
 
 type GoBuilderServerConfig struct {
@@ -21,6 +31,10 @@ type GoBuilderServerConfig struct {
 	Cert     string                             `yaml:"cert"`
 	Key      string                             `yaml:"key"`
 	Handler  int                                `yaml:"handler"`
+	Revoked  string                             `yaml:"revoked,omitempty"` // revoked client certificate list
+	// release history directory, empty disable history
+	ReleaseDir   string `yaml:"release-dir,omitempty"`
+	KeepReleases int    `yaml:"keep-releases,omitempty"`
 }
 
 func (c GoBuilderServerConfig) GetTlsCert() (tls.Certificate, error) {
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// VersionState version of each package after last successful build, kept out of config
+type VersionState struct {
+	Packages map[string]Version `yaml:"packages"`
+}
+
+func StatePath(configPath string) string {
+	return configPath + ".state"
+}
+
+func ReadVersionState(path string) (*VersionState, error) {
+	state := &VersionState{Packages: make(map[string]Version)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, err
+	}
+	if state.Packages == nil {
+		state.Packages = make(map[string]Version)
+	}
+
+	return state, nil
+}
+
+// ApplyVersionState override config version with saved state newer than it,
+// raised config version win over stale state
+func ApplyVersionState(state *VersionState) {
+	for name, v := range state.Packages {
+		pkg, ok := BuildConfig.Packages[name]
+		if !ok || !pkg.ConfigVersion() {
+			continue
+		}
+		if pkg.Version == nil || newerVersion(v, *pkg.Version) {
+			version := v
+			pkg.Version = &version
+		}
+	}
+}
+
+// newerVersion a precede b by semver precedence, metadata ignored
+func newerVersion(a, b Version) bool {
+	if a.Major != b.Major {
+		return a.Major > b.Major
+	}
+	if a.Minor != b.Minor {
+		return a.Minor > b.Minor
+	}
+	if a.Patch != b.Patch {
+		return a.Patch > b.Patch
+	}
+	return comparePrerelease(a.Prerelease, b.Prerelease) > 0
+}
+
+// comparePrerelease release precede any prerelease, identifiers compared in order,
+// numeric ones numerically and lower than alphanumeric, more identifiers win on equal prefix
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an > bn {
+					return 1
+				}
+				return -1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			if as[i] > bs[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+
+	switch {
+	case len(as) > len(bs):
+		return 1
+	case len(as) < len(bs):
+		return -1
+	}
+	return 0
+}
+
+// lockPath hold exclusive lock of `<path>.lock` until unlock, serialize gobuilder processes
+func lockPath(path string) (func(), error) {
+	f, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(f); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+	}, nil
+}
+
+// LockVersionState lock state file from read through build until SaveVersionState,
+// concurrent gobuilder wait instead of stamp the same version, state re-read as it may be saved after load
+func LockVersionState(path string) (func(), error) {
+	unlock, err := lockPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := ReadVersionState(path)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	ApplyVersionState(state)
+
+	return unlock, nil
+}
+
+// SaveVersionState merge bumped versions into state file, newer version on disk win, caller hold LockVersionState
+func SaveVersionState(path string, bumped map[string]Version) error {
+	if len(bumped) == 0 {
+		return nil
+	}
+
+	state, err := ReadVersionState(path)
+	if err != nil {
+		return err
+	}
+
+	for name, v := range bumped {
+		if disk, ok := state.Packages[name]; ok && newerVersion(disk, v) {
+			continue
+		}
+		state.Packages[name] = v
+	}
+
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(data); err != nil {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		_ = temp.Close()
+		_ = os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		_ = os.Remove(temp.Name())
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
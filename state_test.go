@@ -0,0 +1,122 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestComparePrerelease(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"rc.1", "rc.1", 0},
+		{"", "rc.1", 1},
+		{"rc.1", "", -1},
+		{"alpha.2", "alpha.10", -1},
+		{"alpha.10", "alpha.2", 1},
+		{"1", "alpha", -1},
+		{"alpha", "1", 1},
+		{"rc.1", "rc.beta", -1},
+		{"alpha", "beta", -1},
+		{"alpha", "alpha.1", -1},
+		{"alpha.1", "alpha", 1},
+		{"rc.1", "rc.1.dev.3", -1},
+		{"rc.1.dev.3", "rc.2", -1},
+	}
+
+	for _, test := range tests {
+		if got := comparePrerelease(test.a, test.b); got != test.want {
+			t.Errorf("%q <> %q %d want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		newer bool
+	}{
+		{"1.0.1", "1.0.0", true},
+		{"1.0.0", "1.0.1", false},
+		{"1.1.0", "1.0.9", true},
+		{"2.0.0", "1.9.9", true},
+		{"1.0.0", "1.0.0", false},
+		{"1.0.0", "1.0.0-rc.1", true},
+		{"1.0.0-rc.1", "1.0.0", false},
+		{"1.0.0-rc.2", "1.0.0-rc.1", true},
+		{"1.0.0-rc.1", "0.9.9", true},
+		{"1.0.0+build.2", "1.0.0+build.1", false},
+	}
+
+	for _, test := range tests {
+		a, err := ParseVersion(test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseVersion(test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := newerVersion(a, b); got != test.newer {
+			t.Errorf("%s newer than %s %v want %v", test.a, test.b, got, test.newer)
+		}
+	}
+}
+
+func TestLockVersionState(t *testing.T) {
+	defer func(packages map[string]*GoBuilderPackage) { BuildConfig.Packages = packages }(BuildConfig.Packages)
+	BuildConfig.Packages = map[string]*GoBuilderPackage{"api": {Version: &Version{Major: 1, Patch: 4}}}
+
+	path := filepath.Join(t.TempDir(), ".gobuilder.state")
+	// another gobuilder saved after config load
+	if err := os.WriteFile(path, []byte("packages: {api: {major: 1, minor: 0, patch: 7}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := LockVersionState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := BuildConfig.Packages["api"].BuildVersion(); v.String() != "1.0.7" {
+		t.Fatalf("version under lock %s want 1.0.7", v)
+	}
+
+	locked := make(chan func())
+	go func() {
+		unlock, err := LockVersionState(path)
+		if err != nil {
+			t.Error(err)
+			close(locked)
+			return
+		}
+		locked <- unlock
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("second lock acquired while state locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := SaveVersionState(path, map[string]Version{"api": {Major: 1, Patch: 8}}); err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+
+	select {
+	case unlock, ok := <-locked:
+		if !ok {
+			return
+		}
+		defer unlock()
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lock not acquired after unlock")
+	}
+	if v := BuildConfig.Packages["api"].BuildVersion(); v.String() != "1.0.8" {
+		t.Errorf("version after wait %s want 1.0.8", v)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/lucas-clemente/quic-go"
+	"gobuilder/log"
+	"gobuilder/quicpkg"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+)
+
+const (
+	StatusInSync        = "in-sync"
+	StatusOutOfDate     = "out-of-date"
+	StatusMissingLocal  = "missing-local"
+	StatusMissingRemote = "missing-remote"
+	StatusUnreachable   = "unreachable"
+	StatusError         = "error"
+)
+
+func FileSignature(path string) ([]byte, int64, error) {
+	o, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer o.Close()
+
+	hashFunc := sha256.New()
+	size, err := io.Copy(hashFunc, o)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return hashFunc.Sum(nil), size, nil
+}
+
+func RemotePackageInfo(remote quic.Connection, name string) (*quicpkg.PacketPackageInfo, error) {
+	request, err := quicpkg.NewPackageName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := OpenRequest(remote, quicpkg.OperationPackageInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := request.WriteWith(stream, quicpkg.OperationPackageInfo); err != nil {
+		return nil, err
+	}
+
+	if _, err := quicpkg.ReadOp(stream); err != nil {
+		return nil, err
+	}
+
+	var response quicpkg.PacketPackageInfo
+	if err := response.Read(stream); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// shortHash first 12 hex digits of signature, remote may send fewer bytes
+func shortHash(signature []byte) string {
+	if len(signature) == 0 {
+		return "-"
+	}
+	return shortHex(hex.EncodeToString(signature))
+}
+
+func PackageStatus(name string, pkg *GoBuilderPackage, target string) (string, []byte, []byte, error) {
+	remote, err := DialDeploy(target)
+	if err != nil {
+		return StatusUnreachable, nil, nil, err
+	}
+	defer remote.CloseWithError(0, "")
+
+	return RemoteStatus(remote, name, filepath.Join(pkg.Dest, name))
+}
+
+// RemoteStatus compare local binary sha256 with remote package
+func RemoteStatus(remote quic.Connection, name, binaryPath string) (string, []byte, []byte, error) {
+	local, _, err := FileSignature(binaryPath)
+	if err != nil && !os.IsNotExist(err) {
+		return StatusError, nil, nil, err
+	}
+
+	info, err := RemotePackageInfo(remote, name)
+	if err != nil {
+		return StatusError, local, nil, err
+	}
+
+	switch {
+	case info.Signature.Size == 0:
+		return StatusMissingRemote, local, nil, nil
+	case len(local) == 0:
+		return StatusMissingLocal, nil, info.Signature.Data, nil
+	case bytes.Equal(local, info.Signature.Data):
+		return StatusInSync, local, info.Signature.Data, nil
+	}
+
+	return StatusOutOfDate, local, info.Signature.Data, nil
+}
+
+func StatusHandle(args []string) error {
+	names, err := ParseCommand(NewFlagSet("status"), args)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		for name := range BuildConfig.Packages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	var tasks []Task
+	for _, name := range names {
+		if pkg, ok := BuildConfig.Packages[name]; ok {
+			expanded, err := pkg.Tasks(name)
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, expanded...)
+			continue
+		}
+		t, err := LookupTask(name)
+		if err != nil {
+			return err
+		}
+		tasks = append(tasks, t)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "PACKAGE\tTARGET\tSTATUS\tLOCAL\tREMOTE")
+
+	for _, t := range tasks {
+		for _, target := range BuildConfig.ResolveTargets(t.Package.Deploy) {
+			status, local, remote, err := PackageStatus(t.Output, t.Package, target)
+			if err != nil {
+				log.Debug("status", t.Output, "-", target, err)
+			}
+
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Output, target, status, shortHash(local), shortHash(remote))
+		}
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestShortHash(t *testing.T) {
+	tests := []struct {
+		signature []byte
+		want      string
+	}{
+		{nil, "-"},
+		{[]byte{0xab, 0xcd}, "abcd"},
+		{[]byte{1, 2, 3, 4, 5, 6}, "010203040506"},
+		{[]byte{1, 2, 3, 4, 5, 6, 7, 8}, "010203040506"},
+	}
+
+	for _, test := range tests {
+		if got := shortHash(test.signature); got != test.want {
+			t.Errorf("%x short %s want %s", test.signature, got, test.want)
+		}
+	}
+}
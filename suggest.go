@@ -0,0 +1,81 @@
+package main
+
+import "sort"
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// packageNames package names and matrix output names of loaded config
+func packageNames() []string {
+	return configPackageNames(&BuildConfig)
+}
+
+func configPackageNames(config *GoBuilderConfig) []string {
+	var names []string
+	for name, pkg := range config.Packages {
+		names = append(names, name)
+		if tasks, err := pkg.Tasks(name); err == nil && len(tasks) > 1 {
+			for _, t := range tasks {
+				names = append(names, t.Output)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// closest candidate within edit distance of about half the name
+func closest(name string, candidates []string) (string, bool) {
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		if d := levenshtein(name, candidate); bestDistance < 0 || d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best, bestDistance >= 0 && bestDistance <= len([]rune(name))/2+1
+}
+
+// NotFoundError package not found error with closest package name suggested
+func NotFoundError(name string) error {
+	message := "package `" + name + "` not found"
+	if best, ok := closest(name, packageNames()); ok {
+		message += ", did you mean `" + best + "`?"
+	}
+	return &notFoundError{message: message}
+}
+
+type notFoundError struct {
+	message string
+}
+
+func (e *notFoundError) Error() string {
+	return e.message
+}
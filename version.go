@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	VersionSourceConfig       = "config"
+	VersionSourceGitTag       = "git-tag"
+	VersionSourceConventional = "conventional"
+)
+
+const (
+	bumpNone = iota
+	bumpPatch
+	bumpMinor
+	bumpMajor
+)
+
+var conventionalHeader = regexp.MustCompile(`^(\w+)(\([^)]*\))?(!)?:`)
+
+// BuildVersion version embed into binary, resolved from `version-source` or config version
+func (p *GoBuilderPackage) BuildVersion() Version {
+	if p.resolvedVersion != nil {
+		return *p.resolvedVersion
+	}
+	if p.Version == nil {
+		return Version{}
+	}
+	return p.Version.Clone()
+}
+
+// ConfigVersion version come from config and bump after build
+func (p *GoBuilderPackage) ConfigVersion() bool {
+	return p.VersionSource == "" || p.VersionSource == VersionSourceConfig
+}
+
+// NextVersion version after successful build, with reason when not bumped
+func (p *GoBuilderPackage) NextVersion() (Version, string) {
+	version := p.BuildVersion()
+	switch {
+	case !p.ConfigVersion():
+		return version, p.VersionSource
+	case CLI.NoVersionBump:
+		return version, "no bump, --no-version-bump"
+	case !BuildConfig.AutoUpgrade:
+		return version, "no bump, auto-upgrade off"
+	}
+	version.Patch += 1
+	return version, ""
+}
+
+// ResolveVersion resolve version of git sources once before build, config source keep config version
+func (p *GoBuilderPackage) ResolveVersion() error {
+	var (
+		v   Version
+		err error
+	)
+	switch p.VersionSource {
+	case "", VersionSourceConfig:
+		return nil
+	case VersionSourceGitTag:
+		v, err = GitTagVersion()
+	case VersionSourceConventional:
+		v, err = ConventionalVersion()
+	default:
+		return errors.New("`version-source` " + p.VersionSource + " invalid, config or git-tag or conventional")
+	}
+	if err != nil {
+		return err
+	}
+
+	p.resolvedVersion = &v
+	return nil
+}
+
+func gitOutput(args ...string) (string, error) {
+	cmd := NewGitCommand(args...)
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	if err := cmd.Wait(); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(cmd.Stdout())), nil
+}
+
+// lastTag nearest `v*` tag of HEAD, empty when no tag
+func lastTag() (string, Version, error) {
+	tag, err := gitOutput("describe", "--tags", "--abbrev=0", "--match", "v*")
+	if err != nil {
+		// no tag yet, count from 0.0.0
+		return "", Version{}, nil
+	}
+
+	v, err := ParseVersion(tag)
+	if err != nil {
+		return "", Version{}, errors.New("tag `" + tag + "` not semver")
+	}
+	return tag, v, nil
+}
+
+func commitsSince(tag string) (int, error) {
+	rev := "HEAD"
+	if tag != "" {
+		rev = tag + "..HEAD"
+	}
+	count, err := gitOutput("rev-list", "--count", rev)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(count)
+}
+
+// devVersion next patch as prerelease `dev.<distance>` with commit hash metadata,
+// prerelease tag append `.dev.<distance>` so 1.2.0-rc.1.dev.3 sort after 1.2.0-rc.1
+func devVersion(v Version, distance int, hash string) Version {
+	if v.Prerelease == "" {
+		v.Patch += 1
+		v.Prerelease = "dev." + strconv.Itoa(distance)
+	} else {
+		v.Prerelease += ".dev." + strconv.Itoa(distance)
+	}
+	v.Metadata = hash
+	return v
+}
+
+// GitTagVersion version of nearest `v*` tag, commits after tag build as next patch dev prerelease like 1.2.4-dev.3+abc1234
+func GitTagVersion() (Version, error) {
+	tag, v, err := lastTag()
+	if err != nil {
+		return v, err
+	}
+
+	distance, err := commitsSince(tag)
+	if err != nil {
+		return v, err
+	}
+	if distance == 0 && tag != "" {
+		return v, nil
+	}
+
+	hash, err := gitOutput("rev-parse", "--short", "HEAD")
+	if err != nil {
+		return v, err
+	}
+	return devVersion(v, distance, hash), nil
+}
+
+// ConventionalBump level of conventional commit message, `!` or `BREAKING CHANGE` major, feat minor, others patch
+func ConventionalBump(message string) int {
+	header := strings.SplitN(message, "\n", 2)[0]
+	if strings.Contains(message, "BREAKING CHANGE:") || strings.Contains(message, "BREAKING-CHANGE:") {
+		return bumpMajor
+	}
+
+	m := conventionalHeader.FindStringSubmatch(header)
+	if m == nil {
+		return bumpPatch
+	}
+	if m[3] == "!" {
+		return bumpMajor
+	}
+	if m[1] == "feat" {
+		return bumpMinor
+	}
+	return bumpPatch
+}
+
+// ConventionalVersion bump last tag version by commit messages since the tag
+func ConventionalVersion() (Version, error) {
+	tag, v, err := lastTag()
+	if err != nil {
+		return v, err
+	}
+
+	rev := "HEAD"
+	if tag != "" {
+		rev = tag + "..HEAD"
+	}
+	messages, err := gitOutput("log", "--format=%B%x00", rev)
+	if err != nil {
+		return v, err
+	}
+
+	bump := bumpNone
+	for _, message := range strings.Split(messages, "\x00") {
+		message = strings.TrimSpace(message)
+		if message == "" {
+			continue
+		}
+		if b := ConventionalBump(message); b > bump {
+			bump = b
+		}
+	}
+
+	return bumpVersion(v, bump), nil
+}
+
+// bumpVersion apply bump level to tag version, prerelease tag release as it self when bump not go past it
+func bumpVersion(v Version, bump int) Version {
+	pre := v.Prerelease != ""
+	switch bump {
+	case bumpMajor:
+		if pre && v.Minor == 0 && v.Patch == 0 {
+			return Version{Major: v.Major}
+		}
+		return Version{Major: v.Major + 1}
+	case bumpMinor:
+		if pre && v.Patch == 0 {
+			return Version{Major: v.Major, Minor: v.Minor}
+		}
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	case bumpPatch:
+		if pre {
+			return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+		}
+		return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+	return v
+}
@@ -0,0 +1,154 @@
+package main
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		s       string
+		version Version
+		invalid bool
+	}{
+		{s: "1.2.3", version: Version{Major: 1, Minor: 2, Patch: 3}},
+		{s: "v0.10.0", version: Version{Minor: 10}},
+		{s: "1.0.0-rc.1", version: Version{Major: 1, Prerelease: "rc.1"}},
+		{s: "1.0.0+build.5", version: Version{Major: 1, Metadata: "build.5"}},
+		{s: "2.1.0-dev.4+g1a2b3c", version: Version{Major: 2, Minor: 1, Prerelease: "dev.4", Metadata: "g1a2b3c"}},
+		{s: "1.0.0-beta-2", version: Version{Major: 1, Prerelease: "beta-2"}},
+		{s: "1.2", invalid: true},
+		{s: "1.2.3.4", invalid: true},
+		{s: "1.x.3", invalid: true},
+		{s: "1.2.-3", invalid: true},
+		{s: "1.2.3-", invalid: true},
+		{s: "1.2.3+", invalid: true},
+		{s: "", invalid: true},
+	}
+
+	for _, test := range tests {
+		version, err := ParseVersion(test.s)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%q parsed %+v want error", test.s, version)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q %v", test.s, err)
+			continue
+		}
+		if version != test.version {
+			t.Errorf("%q parsed %+v want %+v", test.s, version, test.version)
+		}
+		if s := version.String(); s != test.s && "v"+s != test.s {
+			t.Errorf("%q string %s", test.s, s)
+		}
+	}
+}
+
+func TestConventionalBump(t *testing.T) {
+	tests := []struct {
+		message string
+		bump    int
+	}{
+		{"fix: nil config panic", bumpPatch},
+		{"fix(deploy): retry dial", bumpPatch},
+		{"feat: add plan command", bumpMinor},
+		{"feat(server): keygen rotate", bumpMinor},
+		{"feat!: drop config v1", bumpMajor},
+		{"refactor(cli)!: rename flags", bumpMajor},
+		{"fix: reorder flags\n\nBREAKING CHANGE: -f removed", bumpMajor},
+		{"chore: deps\n\nBREAKING-CHANGE: go 1.18 required", bumpMajor},
+		{"docs: readme", bumpPatch},
+		{"update readme", bumpPatch},
+		{"Merge branch 'feat: x'", bumpPatch},
+		{"feature: not conventional type", bumpPatch},
+	}
+
+	for _, test := range tests {
+		if bump := ConventionalBump(test.message); bump != test.bump {
+			t.Errorf("%q bump %d want %d", test.message, bump, test.bump)
+		}
+	}
+}
+
+func TestNextVersion(t *testing.T) {
+	defer func(noBump, autoUpgrade bool) {
+		CLI.NoVersionBump, BuildConfig.AutoUpgrade = noBump, autoUpgrade
+	}(CLI.NoVersionBump, BuildConfig.AutoUpgrade)
+
+	tests := []struct {
+		source      string
+		noBump      bool
+		autoUpgrade bool
+		want        string
+		bumped      bool
+	}{
+		{"", false, true, "1.0.5", true},
+		{VersionSourceConfig, false, true, "1.0.5", true},
+		{VersionSourceConfig, true, true, "1.0.4", false},
+		{VersionSourceConfig, false, false, "1.0.4", false},
+		{VersionSourceGitTag, false, true, "1.0.4", false},
+	}
+
+	for _, test := range tests {
+		CLI.NoVersionBump, BuildConfig.AutoUpgrade = test.noBump, test.autoUpgrade
+		pkg := &GoBuilderPackage{Version: &Version{Major: 1, Patch: 4}, VersionSource: test.source}
+		next, hold := pkg.NextVersion()
+		if next.String() != test.want || (hold == "") != test.bumped {
+			t.Errorf("%q no-bump %v auto-upgrade %v next %s %q want %s", test.source, test.noBump, test.autoUpgrade, next, hold, test.want)
+		}
+	}
+}
+
+func TestDevVersion(t *testing.T) {
+	tests := []struct {
+		tag  Version
+		want string
+	}{
+		{Version{}, "0.0.1-dev.3+abc1234"},
+		{Version{Major: 1, Minor: 2, Patch: 3}, "1.2.4-dev.3+abc1234"},
+		{Version{Major: 1, Minor: 2, Prerelease: "rc.1"}, "1.2.0-rc.1.dev.3+abc1234"},
+	}
+
+	for _, test := range tests {
+		v := devVersion(test.tag, 3, "abc1234")
+		if v.String() != test.want {
+			t.Errorf("%s dev %s want %s", test.tag, v, test.want)
+		}
+		if !newerVersion(v, test.tag) {
+			t.Errorf("%s dev %s not newer than tag", test.tag, v)
+		}
+	}
+}
+
+func TestBumpVersion(t *testing.T) {
+	tests := []struct {
+		tag  string
+		bump int
+		want string
+	}{
+		{"1.2.3", bumpNone, "1.2.3"},
+		{"1.2.3", bumpPatch, "1.2.4"},
+		{"1.2.3", bumpMinor, "1.3.0"},
+		{"1.2.3", bumpMajor, "2.0.0"},
+		{"1.2.3-rc.1", bumpPatch, "1.2.3"},
+		{"1.2.3-rc.1", bumpMinor, "1.3.0"},
+		{"1.2.3-rc.1", bumpMajor, "2.0.0"},
+		{"2.0.0-rc.1", bumpPatch, "2.0.0"},
+		{"2.0.0-rc.1", bumpMinor, "2.0.0"},
+		{"2.0.0-rc.1", bumpMajor, "2.0.0"},
+		{"3.0.0-rc.1", bumpMajor, "3.0.0"},
+		{"2.1.0-beta", bumpMinor, "2.1.0"},
+		{"2.1.0-beta", bumpMajor, "3.0.0"},
+		{"1.2.3+build.5", bumpPatch, "1.2.4"},
+	}
+
+	for _, test := range tests {
+		v, err := ParseVersion(test.tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bumpVersion(v, test.bump); got.String() != test.want {
+			t.Errorf("%s bump %d %s want %s", test.tag, test.bump, got, test.want)
+		}
+	}
+}